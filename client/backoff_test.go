@@ -0,0 +1,62 @@
+package client
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func sleepTimes(c *Client, n int) []time.Duration {
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = c.getRandomSleepTime()
+	}
+	return out
+}
+
+func TestRandSeedIsReproducible(t *testing.T) {
+	a := newClient(WithRandSeed(42))
+	b := newClient(WithRandSeed(42))
+	other := newClient(WithRandSeed(43))
+	sa, sb, so := sleepTimes(a, 50), sleepTimes(b, 50), sleepTimes(other, 50)
+	same := true
+	for i := range sa {
+		if sa[i] != sb[i] {
+			t.Fatalf("sleep %d = %v and %v with the same seed", i, sa[i], sb[i])
+		}
+		same = same && sa[i] == so[i]
+	}
+	if same {
+		t.Fatal("different seeds produced the same sleeps")
+	}
+
+	// WithRandSource 与同一种子的 WithRandSeed 一致
+	src := newClient(WithRandSource(rand.NewSource(42)))
+	for i, d := range sleepTimes(src, 50) {
+		if d != sa[i] {
+			t.Fatalf("sleep %d = %v with WithRandSource; want %v", i, d, sa[i])
+		}
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		min, max time.Duration
+	}{
+		{"default", nil, 10 * time.Millisecond, 20 * time.Millisecond},
+		{"custom", []Option{WithBackoffBounds(time.Millisecond, 3*time.Millisecond)}, time.Millisecond, 3 * time.Millisecond},
+		{"fixed", []Option{WithBackoffBounds(5*time.Millisecond, 5*time.Millisecond)}, 5 * time.Millisecond, 5 * time.Millisecond},
+		{"max below min ignored", []Option{WithBackoffBounds(5*time.Millisecond, time.Millisecond)}, 10 * time.Millisecond, 20 * time.Millisecond},
+		{"zero min ignored", []Option{WithBackoffBounds(0, time.Millisecond)}, 10 * time.Millisecond, 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		c := newClient(append(tt.opts, WithRandSeed(1))...)
+		for _, d := range sleepTimes(c, 200) {
+			if d < tt.min || d > tt.max || (tt.max > tt.min && d == tt.max) {
+				t.Fatalf("%s: sleep %v outside [%v, %v)", tt.name, d, tt.min, tt.max)
+			}
+		}
+	}
+}
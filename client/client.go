@@ -0,0 +1,125 @@
+package client
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// Client
+// 锁的客户端，持有自己的redis连接、脚本hash、配置和本地状态
+// 多个 Client 之间互不影响，可以连接不同的redis，也可以作为值嵌入到其他结构中
+// 包级别的函数（Lock、Unlock等）使用默认客户端，由 DoInit 初始化
+type Client struct {
+	// redis和shaHashID在重连时被替换，通过 conn / sha 读取
+	connMu    sync.RWMutex
+	redis     redis.UniversalClient
+	opts      interface{}
+	shaHashID string
+	// 保证同一时间只有一个协程因为NOSCRIPT重新加载脚本
+	scriptReloadMu sync.Mutex
+	// 当前的*config，通过 conf 读取；DoInit 重新初始化时整体替换，已经发布的配置不再修改
+	cfg atomic.Value
+
+	// 锁释放通知的订阅
+	notify *notifier
+	// 本客户端持有的锁
+	held *heldRegistry
+	// 正在后台续期的写锁
+	renewals *renewRegistry
+	// 每个key同时进行的加锁尝试
+	gate *keyGate
+	// WatchPrefix 的订阅
+	watches *watchRegistry
+	// 是否处于排空状态
+	draining int32
+	// 最近一次检查到的淘汰策略，无法查询时为空
+	evictionPolicy string
+
+	// 自动重连的状态，保证同一时间只有一个重连在进行
+	reconnectMu      sync.Mutex
+	lastReconnect    time.Time
+	lastReconnectErr error
+	// 连续重连失败的次数，用于计算退避的间隔
+	reconnectFailures uint
+}
+
+// 默认客户端，包级别的函数都使用它
+var std = newClient()
+
+// newClient
+// 创建使用默认配置、还没有连接redis的客户端
+func newClient(options ...Option) *Client {
+	conf := defaultConfig()
+	for _, o := range options {
+		o(conf)
+	}
+	c := &Client{
+		notify: newNotifier(),
+		held:   newHeldRegistry(),
+
+		renewals: newRenewRegistry(),
+		gate:     newKeyGate(),
+		watches:  newWatchRegistry(),
+	}
+	c.cfg.Store(conf)
+	return c
+}
+
+// conf
+// 当前的配置，和正在进行的加锁并发读取时不需要加锁
+func (c *Client) conf() *config {
+	return c.cfg.Load().(*config)
+}
+
+// NewClient
+// 创建一个独立的客户端，optObj同 DoInit
+func NewClient(optObj interface{}, options ...Option) (*Client, error) {
+	c := newClient(options...)
+	if err := c.conf().validate(); err != nil {
+		return nil, err
+	}
+	if err := c.connect(optObj); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Default
+// 返回包级别函数使用的默认客户端
+func Default() *Client {
+	return std
+}
+
+// Redis
+// 返回客户端当前使用的redis连接，重连后会变化
+func (c *Client) Redis() redis.UniversalClient {
+	return c.conn()
+}
+
+// NewClientWithEvalSha
+// 创建不连接redis的客户端，所有锁指令通过fn执行（同 WithEvalSha），用于不依赖redis测试加锁、重试的逻辑和回馈的解析
+// 需要redis客户端的功能（通知、Diagnose、LoadLua、重连等）返回 ErrNotInitialized
+func NewClientWithEvalSha(fn EvalShaFunc, options ...Option) (*Client, error) {
+	c := newClient(append(options, WithEvalSha(fn))...)
+	if err := c.conf().validate(); err != nil {
+		return nil, err
+	}
+	c.setSha(scriptSHA1())
+	return c, nil
+}
+
+// Close
+// 停止通知订阅并关闭redis连接，之后不能再使用
+func (c *Client) Close() error {
+	c.notify.stop()
+	c.watches.closeAll()
+	rdb := c.conn()
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Close()
+}
@@ -0,0 +1,110 @@
+package client_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+func TestClientsAreIsolated(t *testing.T) {
+	a, ma, closeA := rwlocktest.NewTestServer(t)
+	b, mb, _ := rwlocktest.NewTestServer(t, client.WithReadExpire(30))
+	ctx := context.Background()
+
+	// 同一个key在两个redis上各自加锁，互不影响
+	a.Lock("order:1", "owner-a", 5)
+	b.Lock("order:1", "owner-b", 5)
+	stA, err := a.Status(ctx, "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stB, err := b.Status(ctx, "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stA.Owner != "owner-a" || stB.Owner != "owner-b" {
+		t.Fatalf("owners = %q, %q; want owner-a, owner-b", stA.Owner, stB.Owner)
+	}
+
+	// 各自只写自己的redis
+	for _, m := range []interface{ Keys() []string }{ma, mb} {
+		if len(m.Keys()) == 0 {
+			t.Fatal("a server has no lock keys")
+		}
+	}
+	b.Unlock("order:1", "owner-b")
+	if stA, _ = a.Status(ctx, "order:1"); stA.Owner != "owner-a" {
+		t.Fatalf("unlock on b released a's lock: %+v", stA)
+	}
+
+	// 配置互不影响
+	limit := time.Duration(client.DefaultReadExpire) * time.Second
+	a.RLockID("order:2", "r")
+	b.RLockID("order:2", "r")
+	if ttl := readerTTL(t, a, "order:2"); ttl > limit {
+		t.Fatalf("a reader ttl = %v; want at most the default %v", ttl, limit)
+	}
+	if ttl := readerTTL(t, b, "order:2"); ttl <= limit {
+		t.Fatalf("b reader ttl = %v; want the 30s from WithReadExpire", ttl)
+	}
+
+	// 关闭一个客户端后另一个仍然可用
+	closeA()
+	if ok, err := b.TryLock("order:1", "owner-b", 5, 0); err != nil || !ok {
+		t.Fatalf("TryLock on b after closing a = %v, %v", ok, err)
+	}
+}
+
+func readerTTL(t *testing.T, c *client.Client, key string) time.Duration {
+	t.Helper()
+	st, err := c.Status(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.ReaderList) != 1 {
+		t.Fatalf("readers = %+v; want one", st.ReaderList)
+	}
+	return st.ReaderList[0].RemainingTTL
+}
+
+// 加锁进行中重新 DoInit 不会和读取配置的协程产生数据竞争（用 -race 运行）
+// 使用默认客户端，不能并行
+func TestDoInitWhileLocking(t *testing.T) {
+	_, cleanup := rwlocktest.InitTestDefault(t, client.WithLogger(nopLogger{}))
+	defer cleanup()
+	addr := client.Default().Redis().(*redis.Client).Options().Addr
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if ok, _ := client.TryLock("reinit:"+id, id, 5, 0); ok {
+					client.UnlockE("reinit:"+id, id)
+				}
+			}
+		}(strconv.Itoa(i))
+	}
+	for i := 0; i < 5; i++ {
+		time.Sleep(5 * time.Millisecond)
+		if err := client.DoInit(&redis.Options{Addr: addr}, client.WithEvictionCheck(client.EvictionIgnore),
+			client.WithLogger(nopLogger{}), client.WithReadExpire(int64(10+i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+)
+
+func TestIsClusterRedirect(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{"MOVED 3999 127.0.0.1:6381", true},
+		{"ASK 3999 127.0.0.1:6381", true},
+		{"TRYAGAIN Multiple keys request during rehashing of slot", true},
+		{"CLUSTERDOWN The cluster is down", true},
+		{EofError, false},
+		{NoScriptError, false},
+		{"ERR MOVED is not a prefix here", false},
+	}
+	for _, tt := range tests {
+		if got := isClusterRedirect(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("isClusterRedirect(%q) = %t; want %t", tt.msg, got, tt.want)
+		}
+	}
+}
+
+// 集群重定向漏到客户端时重试，不触发重连或者重新加载脚本
+func TestClusterRedirectIsRetried(t *testing.T) {
+	replies := []error{
+		errors.New("MOVED 3999 127.0.0.1:6381"),
+		errors.New("ASK 3999 127.0.0.1:6381"),
+		errors.New("TRYAGAIN Multiple keys request during rehashing of slot"),
+		errors.New("CLUSTERDOWN The cluster is down"),
+	}
+	calls := 0
+	metrics := &countMetrics{}
+	c, err := NewClientWithEvalSha(func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		calls++
+		if calls <= len(replies) {
+			return nil, replies[calls-1]
+		}
+		return `{"opRet":true,"status":"ok"}`, nil
+	}, WithMetrics(metrics), WithBackoffBounds(time.Millisecond, 2*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Lock("order:1", "a", 5)
+	if calls != len(replies)+1 {
+		t.Fatalf("calls = %d; want %d", calls, len(replies)+1)
+	}
+	if metrics.reconnects != 0 || metrics.reloads != 0 {
+		t.Fatalf("reconnects = %d, reloads = %d; want none", metrics.reconnects, metrics.reloads)
+	}
+}
+
+// 集群中一个节点丢失了脚本（例如重启或者新加入）时，下一次加锁遇到NOSCRIPT，重新加载后成功
+func TestClusterNoScriptReloads(t *testing.T) {
+	m1, m2 := miniredis.RunT(t), miniredis.RunT(t)
+	slots := func(ctx context.Context) ([]redis.ClusterSlot, error) {
+		return []redis.ClusterSlot{
+			{Start: 0, End: 8191, Nodes: []redis.ClusterNode{{Addr: m1.Addr()}}},
+			{Start: 8192, End: 16383, Nodes: []redis.ClusterNode{{Addr: m2.Addr()}}},
+		}, nil
+	}
+	metrics := &countMetrics{}
+	c, err := NewClient(&redis.ClusterOptions{ClusterSlots: slots},
+		WithEvictionCheck(EvictionIgnore), WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	cluster := c.Redis().(*redis.ClusterClient)
+	// 找一个在m2上的key
+	key := ""
+	for i := 0; i < 100 && key == ""; i++ {
+		k := "order:" + strconv.Itoa(i)
+		node, err := cluster.MasterForKey(context.Background(), k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.Options().Addr == m2.Addr() {
+			key = k
+		}
+	}
+	if key == "" {
+		t.Fatal("no key maps to the second node")
+	}
+
+	flushScripts(t, m2)
+	if err := c.LockUntil(context.Background(), key, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&metrics.reloads); n != 1 {
+		t.Fatalf("script reloads = %d; want 1", n)
+	}
+	st, err := c.Status(context.Background(), key)
+	if err != nil || st.Owner != "a" {
+		t.Fatalf("Status = %+v, %v; want owner a", st, err)
+	}
+	// 脚本已经加载到所有节点，之后不再重新加载
+	c.Unlock(key, "a")
+	if n := atomic.LoadInt32(&metrics.reloads); n != 1 {
+		t.Fatalf("script reloads after unlock = %d; want 1", n)
+	}
+}
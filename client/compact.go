@@ -0,0 +1,59 @@
+package client
+
+import "context"
+
+// 可以使用整数回馈的指令，调用方只关心成功、占用还是错误
+var compactCmds = map[string]bool{
+	LockCmd:      true,
+	UnlockCmd:    true,
+	RLockCmd:     true,
+	RUnlockCmd:   true,
+	SRLockCmd:    true,
+	SRUnlockCmd:  true,
+	ExtendCmd:    true,
+	RExtendCmd:   true,
+	SRExtendCmd:  true,
+	ReacquireCmd: true,
+	UpgradeCmd:   true,
+	LockIfCmd:    true,
+	HandoffCmd:   true,
+	LeaveCmd:     true,
+	WRLockCmd:    true,
+	WRUnlockCmd:  true,
+	ShortenCmd:   true,
+}
+
+// ctx中标记需要完整回馈的key，用于读取读者数量、排队位置等字段的调用方
+type fullReplyKey struct{}
+
+// withFullReply
+// 本次调用即使开启了 WithCompactReply 也使用JSON回馈
+func withFullReply(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fullReplyKey{}, true)
+}
+
+// useCompactReply
+// 本次调用是否使用整数回馈
+func (c *Client) useCompactReply(ctx context.Context, lockCmd string) bool {
+	if !c.conf().compactReply || !compactCmds[lockCmd] {
+		return false
+	}
+	// 事件需要脚本回馈的持有者和元数据
+	if c.conf().onEvent != nil && (lockCmd == LockCmd || lockCmd == UnlockCmd) {
+		return false
+	}
+	full, _ := ctx.Value(fullReplyKey{}).(bool)
+	return !full
+}
+
+// compactReply
+// 把脚本返回的整数转换成回馈，1为成功，0为占用
+func compactReply(n int64) (*responseLock, bool) {
+	switch n {
+	case 1:
+		return &responseLock{OpRet: true, Status: StatusOK}, true
+	case 0:
+		return &responseLock{Status: StatusBusy}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,395 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LockUntil
+// 写锁，锁的过期时间由ctx的deadline决定
+// ctx没有deadline时使用默认的写锁过期时间，ctx取消时立即返回ctx.Err()
+func LockUntil(ctx context.Context, key, uniqID string) error {
+	return std.LockUntil(ctx, key, uniqID)
+}
+
+// LockUntil
+// 同 LockUntil
+func (c *Client) LockUntil(ctx context.Context, key, uniqID string) error {
+	return c.acquire(ctx, key, uniqID, LockCmd, expireFromContext(ctx, DefaultLockExpire))
+}
+
+// RLockUntil
+// 读锁，读者的过期时间由ctx的deadline决定
+// ctx没有deadline时使用配置的读锁过期时间，ctx取消时立即返回ctx.Err()
+// 释放时调用 RUnlockID(key, uniqID)
+func RLockUntil(ctx context.Context, key, uniqID string) error {
+	return std.RLockUntil(ctx, key, uniqID)
+}
+
+// RLockUntil
+// 同 RLockUntil
+func (c *Client) RLockUntil(ctx context.Context, key, uniqID string) error {
+	if len(uniqID) <= 0 {
+		return errors.New("rlock uniqID is nil")
+	}
+	rlockCmd, _, _ := c.readCmds()
+	return c.acquire(ctx, key, uniqID, rlockCmd, expireFromContext(ctx, c.conf().readExpire))
+}
+
+// expireFromContext
+// 根据ctx的deadline计算过期时间（秒），向上取整，限制在[1, MaxExpire]之间
+func expireFromContext(ctx context.Context, def int64) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return def
+	}
+	// 先用浮点比较，避免很远的deadline转换成int64时溢出
+	secs := math.Ceil(time.Until(deadline).Seconds())
+	if secs >= float64(MaxExpire) {
+		return MaxExpire
+	}
+	if secs < 1 {
+		return 1
+	}
+	return int64(secs)
+}
+
+// acquire
+// 带ctx的加锁循环，使用客户端配置的重试参数
+// 每次EvalSha都直接使用调用方的ctx，go-redis会按ctx的deadline设置命令的读写超时（取和ReadTimeout/WriteTimeout中较早的），
+// deadline很短时命令本身会很快失败，循环随后返回ctx.Err()
+// 脚本返回错误时直接返回，ctx取消时立即返回ctx.Err()
+// 重试的总时长超过 WithMaxAcquireDuration 时返回 ErrAcquireTimeout
+func (c *Client) acquire(ctx context.Context, key, uniqID, lockCmd string, expireTime int64) error {
+	_, err := c.acquireReply(ctx, key, uniqID, lockCmd, expireTime, AcquirePolicy{})
+	return err
+}
+
+// acquireReply
+// 同 acquire，成功时返回脚本的回馈；所有加锁方式的重试都在这里完成
+// policy为本次加锁的重试参数，时长限制和 WithMaxAcquireDuration 同时生效，为0的字段使用客户端的配置
+// extra为追加的脚本参数
+func (c *Client) acquireReply(ctx context.Context, key, uniqID, lockCmd string, expireTime int64, policy AcquirePolicy, extra ...string) (_ *responseLock, err error) {
+	if len(key) <= 0 {
+		return nil, errors.New("lock key is nil")
+	}
+	if c.Draining() {
+		return nil, ErrDraining
+	}
+	if c.conf().maxHeld > 0 && c.held.exceeds(lockCmd, key, uniqID, c.conf().maxHeld) {
+		return nil, ErrTooManyLocks
+	}
+	if c.conf().selfDeadlockCheck && c.held.selfConflict(lockCmd, key, uniqID) {
+		return nil, ErrSelfDeadlock
+	}
+	if c.conf().runtimeTrace {
+		var end func(error)
+		ctx, end = startTrace(ctx, key, lockCmd)
+		defer func() { end(err) }()
+	}
+	start := time.Now()
+	var deadline time.Time
+	if c.conf().maxAcquireDuration > 0 {
+		deadline = start.Add(c.conf().maxAcquireDuration)
+	}
+	// 两个时长限制取较早的一个，记录是否是policy的限制先到
+	policyDeadline := false
+	if policy.MaxDuration > 0 {
+		if d := start.Add(policy.MaxDuration); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+			policyDeadline = true
+		}
+	}
+	attempts := 0
+	slowReported := false
+	acquired := false
+	// 最后一次请求因为ctx取消而失败，脚本可能已经加锁成功
+	unknown := false
+	// 最后一次尝试的脚本已经离开了等待队列
+	left := false
+	write := lockCmd == LockCmd || lockCmd == UpgradeCmd || lockCmd == LockGetCmd
+	// 已经持有这把写锁时不能释放
+	heldBefore := write && c.held.holds(key, uniqID, true)
+	if write {
+		// 放弃等待写锁时离开等待队列，撤销升级意向
+		defer func() {
+			if !acquired && attempts > 0 && !left {
+				c.leaveQueue(key, uniqID)
+			}
+			// 不知道有没有加锁成功时释放一次，脚本只会删除自己持有的锁
+			// 升级失败时不能释放，否则连原来的读锁也一起丢了
+			if !acquired && unknown && lockCmd != UpgradeCmd && !heldBefore {
+				c.releaseUnknown(key, uniqID)
+			}
+		}()
+		checkLockOrder(c.conf().logger, key, lockCmd, uniqID)
+	}
+	acquireID := nextAcquireID()
+	stats, _ := ctx.Value(acquireStatsKey{}).(*acquireStats)
+	observer, _ := c.conf().metrics.(AcquireMetrics)
+	if stats != nil || observer != nil {
+		defer func() {
+			if stats != nil {
+				stats.attempts = attempts
+				stats.acquireID = acquireID
+			}
+			if observer != nil {
+				observer.ObserveAcquire(key, acquireID, attempts, time.Since(start), err)
+			}
+		}()
+	}
+	onPosition, _ := ctx.Value(queuePositionKey{}).(func(pos int))
+	// 写锁的ARGV[3]为是否返回排队位置的标记
+	wantPosition := onPosition != nil && (lockCmd == LockCmd || lockCmd == LockGetCmd)
+	if wantPosition {
+		ctx = withFullReply(ctx)
+		flagged := []string{"1"}
+		if len(extra) > 1 {
+			flagged = append(flagged, extra[1:]...)
+		}
+		extra = flagged
+	}
+	extra = c.readExtra(lockCmd, extra)
+	if traceID := traceIDFromContext(ctx); len(traceID) > 0 && lockCmd == LockCmd {
+		if len(traceID) > MaxTraceIDSize {
+			return nil, ErrTraceIDTooLarge
+		}
+		extra = withTraceArg(extra, traceID)
+	}
+	lastPosition := 0
+	// 最近一次回馈中冷却期剩余的时间
+	var cooldown time.Duration
+	if c.conf().maxInflight > 0 {
+		leave, err := c.gate.enter(ctx, key, c.conf().maxInflight, deadline)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+	}
+	var wakeup <-chan struct{}
+	if c.conf().notify {
+		ch, cancel := c.notify.register(key)
+		defer cancel()
+		wakeup = ch
+	}
+	if c.conf().initialJitter > 0 {
+		jitter := time.Duration(c.conf().rand.Int63n(int64(c.conf().initialJitter)))
+		if !deadline.IsZero() {
+			// 和重试的睡眠一样不超过剩余的预算，到期后在循环开头返回超时
+			if remain := time.Until(deadline); remain < jitter {
+				jitter = remain
+			}
+		}
+		timer := time.NewTimer(jitter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			if cooldown > 0 {
+				return nil, fmt.Errorf("%w: %s remaining after %d attempts", ErrInCooldown, cooldown, attempts)
+			}
+			if policyDeadline {
+				return nil, fmt.Errorf("%w: max wait %s exceeded after %d attempts", ErrAcquireTimeout, policy.MaxDuration, attempts)
+			}
+			return nil, ErrAcquireTimeout
+		}
+		if !slowReported && c.conf().slowAcquire > 0 && c.conf().onSlowAcquire != nil {
+			if elapsed := time.Since(start); elapsed >= c.conf().slowAcquire {
+				slowReported = true
+				c.conf().onSlowAcquire(key, elapsed)
+			}
+		}
+		if c.conf().limiter != nil {
+			if wait := c.conf().limiter.reserve(key); wait > 0 {
+				limited := false
+				if !deadline.IsZero() {
+					// 等待不超过剩余的预算，到期后回到循环开头返回超时
+					if remain := time.Until(deadline); remain < wait {
+						wait, limited = remain, true
+					}
+				}
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					// 没有用到的令牌要退还
+					c.conf().limiter.cancel(key)
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+				if limited {
+					c.conf().limiter.cancel(key)
+					continue
+				}
+			}
+		}
+		attempts++
+		cmdCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.CommandTimeout > 0 {
+			cmdCtx, cancel = context.WithTimeout(ctx, policy.CommandTimeout)
+		}
+		args := extra
+		// 知道是最后一次尝试时让脚本在失败时直接离开等待队列，省掉放弃时的LEAVE
+		last := lockCmd == LockCmd && policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts
+		if last {
+			args = withLastAttemptArg(extra)
+		}
+		res, err := c.sendLock(cmdCtx, key, uniqID, lockCmd, expireTime, args...)
+		left = last && err == nil
+		// 单次请求超时和ctx取消一样，脚本可能已经执行；之后的尝试成功了也不能说明这次没有加锁，一直保留
+		uncertain := err != nil && cmdCtx.Err() != nil
+		unknown = unknown || uncertain
+		timedOut := uncertain && ctx.Err() == nil
+		cancel()
+		if uncertain {
+			// 之后的回馈需要带上持有者，才能判断是不是自己已经拿到了锁
+			ctx = withFullReply(ctx)
+		}
+		if err != nil {
+			// 关闭了自动重连，EOF直接返回给调用方
+			if !c.conf().autoReinit && err.Error() == EofError {
+				return nil, err
+			}
+			// 脚本返回值无法解析或者还没初始化，不再重试
+			if errors.Is(err, ErrMalformedReply) || err == ErrNotInitialized {
+				return nil, err
+			}
+			// 网络或脚本加载的问题，处理后重试
+			if !uncertain || timedOut {
+				logWith(c.conf().logger, Fields{FieldKey: key, FieldOp: lockCmd, FieldToken: uniqID, FieldAttempt: attempts, FieldAcquireID: acquireID}).
+					Printf("acquire %d lock %s attempt %d: %v", acquireID, key, attempts, err)
+			}
+			c.handleErrorContext(ctx, err)
+		} else {
+			state := res.State()
+			// 超时的那次请求其实已经加锁成功，写锁不可重入，之后的尝试会因为自己持有而失败
+			// 这时认为已经拿到锁，并离开失败时进入的等待队列
+			if state == StatusBusy && unknown && !heldBefore && lockCmd == LockCmd && res.Owner == uniqID {
+				state = StatusOK
+				c.leaveQueue(key, uniqID)
+			}
+			switch state {
+			case StatusOK:
+				acquired = true
+				c.held.track(lockCmd, key, uniqID)
+				if write {
+					recordLockAcquired(key)
+					c.emitEvent(EventAcquired, key, uniqID, res.Meta, acquireID)
+				}
+				return res, nil
+			case StatusError:
+				return nil, replyError(res)
+			}
+			// StatusBusy: 锁被占用，等待后重试
+			cooldown = time.Duration(res.Cooldown) * time.Millisecond
+			if wantPosition && res.Position > 0 && res.Position != lastPosition {
+				lastPosition = res.Position
+				onPosition(res.Position)
+			}
+		}
+
+		if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+			if cooldown > 0 {
+				return nil, fmt.Errorf("%w: %s remaining", ErrInCooldown, cooldown)
+			}
+			return nil, fmt.Errorf("%w: %d attempts in %s", ErrMaxAttempts, attempts, time.Since(start))
+		}
+
+		sleep := c.getRandomSleepTime()
+		if policy.Backoff != nil {
+			sleep = policy.Backoff(attempts)
+		}
+		if policy.Jitter > 0 {
+			sleep += time.Duration(c.conf().rand.Int63n(int64(policy.Jitter)))
+		}
+		if wakeup != nil && c.conf().notifyFallback > 0 {
+			// 有通知时立即唤醒，睡眠只是兜底
+			sleep = c.conf().notifyFallback
+		}
+		if cooldown > sleep {
+			// 冷却期内重试没有意义，直接等到冷却期结束
+			sleep = cooldown
+		}
+		if !deadline.IsZero() {
+			// 睡眠不超过剩余的预算，到期后再检查一次
+			if remain := time.Until(deadline); remain < sleep {
+				sleep = remain
+			}
+		}
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-wakeup:
+			// 锁被释放了，立即重试
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// readExtra
+// 读锁的最后两个参数为公平窗口（毫秒）和写者等待时放行的读者数量，其他指令原样返回
+func (c *Client) readExtra(lockCmd string, extra []string) []string {
+	if (c.conf().readerFairness > 0 || c.conf().readBatch > 0) && (lockCmd == RLockCmd || lockCmd == SRLockCmd || lockCmd == RLockGetCmd) {
+		extra = append(extra, strconv.FormatInt(c.conf().readerFairness.Milliseconds(), 10))
+		if c.conf().readBatch > 0 {
+			extra = append(extra, strconv.Itoa(c.conf().readBatch))
+		}
+	}
+	return extra
+}
+
+// withLastAttemptArg
+// 写锁的ARGV[10]为最后一次尝试的标记，前面不足的参数补空
+func withLastAttemptArg(extra []string) []string {
+	args := make([]string, 7, 8)
+	copy(args, extra)
+	return append(args, "1")
+}
+
+// 加锁调用的ID，进程内递增
+var acquireSeq uint64
+
+// nextAcquireID
+// 分配一个加锁调用的ID，只是一次原子加，不分配内存
+func nextAcquireID() uint64 {
+	return atomic.AddUint64(&acquireSeq, 1)
+}
+
+// releaseUnknown
+// 尽力而为地释放可能已经拿到的写锁，用于加锁请求被ctx取消、不知道结果的情况
+func (c *Client) releaseUnknown(key, uniqID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.sendLock(ctx, key, uniqID, UnlockCmd, 0); err != nil {
+		// 释放失败时锁可能一直被占用到过期
+		logWith(c.conf().logger, Fields{FieldKey: key, FieldOp: UnlockCmd, FieldToken: uniqID}).
+			Printf("release lock %s after canceled acquire failed: %v", key, err)
+		c.handleError(err)
+	}
+}
+
+// leaveQueue
+// 尽力而为地离开写锁的等待队列，失败时等心跳过期后由其他等待者清理
+func (c *Client) leaveQueue(key, uniqID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := c.sendLock(ctx, key, uniqID, LeaveCmd, 0); err != nil {
+		c.handleError(err)
+	}
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// UnlockWithCooldown
+// 释放写锁，并在cooldown内不允许任何人再加锁（写锁和读锁），冷却期和释放在同一次脚本执行中设置
+// 冷却期内阻塞的加锁会等到冷却期结束，TryLock 等会放弃的加锁返回 ErrInCooldown
+// 锁已经不是自己的时不设置冷却期，返回值同 UnlockE；cooldown小于1毫秒时等同于 UnlockE
+func UnlockWithCooldown(key, uniqID string, cooldown time.Duration) error {
+	return std.UnlockWithCooldown(key, uniqID, cooldown)
+}
+
+// UnlockWithCooldown
+// 同 UnlockWithCooldown
+func (c *Client) UnlockWithCooldown(key, uniqID string, cooldown time.Duration) error {
+	// ARGV[3]：释放后的冷却期（毫秒）
+	err, _ := c.unlock(withFullReply(context.Background()), key, uniqID, strconv.FormatInt(cooldown.Milliseconds(), 10))
+	return err
+}
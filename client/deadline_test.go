@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// ctx的deadline已经过了时直接返回ctx.Err()，不访问redis
+func TestExpiredDeadlineSkipsRedis(t *testing.T) {
+	t.Parallel()
+	var calls int32
+	evalSha := func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return okReply, nil
+	}
+	c, err := client.NewClientWithEvalSha(evalSha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Millisecond))
+	defer cancel()
+
+	if err := c.AcquireWith(ctx, writeSpec("order:1", "a"), client.AcquirePolicy{}); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v; want context.DeadlineExceeded", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("EvalSha calls = %d; want 0", n)
+	}
+}
+
+// 命令直接使用调用方的ctx：redis不回馈时命令在ctx的deadline失败，循环返回ctx.Err()，不用等连接的读超时
+func TestShortDeadlineFailsCommandFast(t *testing.T) {
+	t.Parallel()
+	deadlines := make(chan time.Time, 10)
+	evalSha := func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		if keys[1] != client.LockCmd {
+			// 放弃时的清理
+			return okReply, nil
+		}
+		d, _ := ctx.Deadline()
+		deadlines <- d
+		// 模拟加锁时没有回馈的redis
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	c, err := client.NewClientWithEvalSha(evalSha, client.WithLogger(nopLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	want, _ := ctx.Deadline()
+
+	start := time.Now()
+	err = c.AcquireWith(ctx, writeSpec("order:1", "a"), client.AcquirePolicy{})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("err = %v; want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("acquire returned after %s; want about 50ms", elapsed)
+	}
+	if got := <-deadlines; !got.Equal(want) {
+		t.Fatalf("command deadline = %s; want the caller's %s", got, want)
+	}
+}
+
+// 等待读锁期间ctx被取消时立即返回，不等到下一次重试之后很久
+func TestRLockUntilReturnsOnCancel(t *testing.T) {
+	t.Parallel()
+	c, cleanup := rwlocktest.NewTestClient(t)
+	defer cleanup()
+	c.Lock("order:1", "w", 30)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.RLockUntil(ctx, "order:1", "r1") }()
+	time.Sleep(50 * time.Millisecond)
+	cancelled := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("RLockUntil = %v; want context.Canceled", err)
+		}
+		if elapsed := time.Since(cancelled); elapsed > 100*time.Millisecond {
+			t.Fatalf("RLockUntil returned %s after cancel; want within 100ms", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RLockUntil did not return after cancel")
+	}
+	if st, err := c.Status(context.Background(), "order:1"); err != nil || len(st.ReaderList) != 0 {
+		t.Fatalf("Status = %+v, %v; want no readers", st, err)
+	}
+}
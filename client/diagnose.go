@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/lua"
+)
+
+// Diagnose 使用的默认探测key
+const DefaultCanaryKey = "_rwlock_canary__"
+
+// CheckResult
+// 单项检查的结果
+type CheckResult struct {
+	Name    string
+	Latency time.Duration
+	Err     error
+}
+
+// OK
+// 检查是否通过
+func (c CheckResult) OK() bool {
+	return c.Err == nil
+}
+
+// String
+// 格式如 "ping ok (1.2ms)"、"script failed: script xxx not loaded (800µs)"
+func (c CheckResult) String() string {
+	if c.OK() {
+		return fmt.Sprintf("%s ok (%s)", c.Name, c.Latency)
+	}
+	return fmt.Sprintf("%s failed: %v (%s)", c.Name, c.Err, c.Latency)
+}
+
+// NodeResult
+// 集群模式下单个master节点的检查结果
+type NodeResult struct {
+	Addr string
+	// ping的耗时和错误
+	Latency time.Duration
+	Err     error
+	// 脚本hash是否已加载到这个节点，缺少脚本的节点会间歇性地返回NOSCRIPT
+	ScriptLoaded bool
+	// SCRIPT EXISTS失败时的错误
+	ScriptErr error
+}
+
+// String
+// 格式如 "10.0.0.1:7000 ok (1.2ms)"、"10.0.0.2:7000 script missing (900µs)"
+func (n NodeResult) String() string {
+	switch {
+	case n.Err != nil:
+		return fmt.Sprintf("%s unreachable: %v (%s)", n.Addr, n.Err, n.Latency)
+	case n.ScriptErr != nil:
+		return fmt.Sprintf("%s script check failed: %v (%s)", n.Addr, n.ScriptErr, n.Latency)
+	case !n.ScriptLoaded:
+		return fmt.Sprintf("%s script missing (%s)", n.Addr, n.Latency)
+	}
+	return fmt.Sprintf("%s ok (%s)", n.Addr, n.Latency)
+}
+
+// Report
+// 锁子系统的健康报告
+type Report struct {
+	// ping redis
+	Ping CheckResult
+	// 脚本hash是否已加载到redis
+	Script CheckResult
+	// 脚本版本是否和当前代码一致
+	Version CheckResult
+	// redis中脚本返回的版本号
+	ScriptVersion string
+	// 集群模式下各master节点的可达性和脚本是否已加载，NodeResults按地址排序
+	Nodes       CheckResult
+	NodeResults []NodeResult
+	// 探测key的读写状态
+	Canary       CheckResult
+	CanaryStatus LockStatus
+}
+
+// Checks
+// 返回所有单项检查结果
+func (r Report) Checks() []CheckResult {
+	return []CheckResult{r.Ping, r.Script, r.Version, r.Nodes, r.Canary}
+}
+
+// Healthy
+// 所有检查都通过
+func (r Report) Healthy() bool {
+	for _, c := range r.Checks() {
+		if !c.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Diagnose
+// 检查锁子系统的健康状况
+// 每一项检查单独计时，某一项失败不影响其他项，全部失败项会合并到返回的error中
+func Diagnose(ctx context.Context) (Report, error) {
+	return std.Diagnose(ctx)
+}
+
+// Diagnose
+// 同 Diagnose
+func (c *Client) Diagnose(ctx context.Context) (Report, error) {
+	var r Report
+	if c.conn() == nil {
+		return r, ErrNotInitialized
+	}
+
+	r.Ping = runCheck("ping", func() error {
+		return c.conn().Ping(ctx).Err()
+	})
+	r.Script = runCheck("script", func() error {
+		exists, err := c.conn().ScriptExists(ctx, c.sha()).Result()
+		if err != nil {
+			return err
+		}
+		if len(exists) <= 0 || !exists[0] {
+			return fmt.Errorf("script %s not loaded", c.sha())
+		}
+		return nil
+	})
+	r.Nodes = runCheck("nodes", func() error {
+		cluster, ok := c.conn().(*redis.ClusterClient)
+		if !ok {
+			return nil
+		}
+		// 在探测key之前检查，探测遇到NOSCRIPT时会重新加载脚本
+		// ForEachMaster并发执行，结果需要加锁
+		var mu sync.Mutex
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			start := time.Now()
+			err := node.Ping(ctx).Err()
+			nr := NodeResult{Addr: node.Options().Addr, Latency: time.Since(start), Err: err}
+			if err == nil {
+				exists, err := node.ScriptExists(ctx, c.sha()).Result()
+				nr.ScriptErr = err
+				nr.ScriptLoaded = err == nil && len(exists) > 0 && exists[0]
+			}
+			mu.Lock()
+			r.NodeResults = append(r.NodeResults, nr)
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		sort.Slice(r.NodeResults, func(i, j int) bool { return r.NodeResults[i].Addr < r.NodeResults[j].Addr })
+		var unreachable, missing []string
+		for _, nr := range r.NodeResults {
+			switch {
+			case nr.Err != nil:
+				unreachable = append(unreachable, nr.Addr)
+			case !nr.ScriptLoaded:
+				missing = append(missing, nr.Addr)
+			}
+		}
+		var problems []string
+		if len(unreachable) > 0 {
+			problems = append(problems, "unreachable nodes: "+strings.Join(unreachable, ","))
+		}
+		if len(missing) > 0 {
+			problems = append(problems, "script missing on nodes: "+strings.Join(missing, ",")+" (call LoadLua to reload)")
+		}
+		if len(problems) > 0 {
+			return errors.New(strings.Join(problems, "; "))
+		}
+		return nil
+	})
+	// 探测key的状态和脚本版本号在同一次请求中返回
+	var canaryErr error
+	r.Canary = runCheck("canary", func() error {
+		r.CanaryStatus, r.ScriptVersion, canaryErr = c.status(ctx, c.conf().canaryKey)
+		return canaryErr
+	})
+	r.Version = runCheck("version", func() error {
+		if canaryErr != nil {
+			return canaryErr
+		}
+		if r.ScriptVersion != lua.Version {
+			return fmt.Errorf("script version %q, expect %q", r.ScriptVersion, lua.Version)
+		}
+		return nil
+	})
+	var msgs []string
+	for _, c := range r.Checks() {
+		if !c.OK() {
+			msgs = append(msgs, c.Name+": "+c.Err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return r, errors.New("diagnose failed: " + strings.Join(msgs, "; "))
+	}
+	return r, nil
+}
+
+// runCheck
+// 执行单项检查并计时，panic也视为检查失败
+func runCheck(name string, fn func() error) (c CheckResult) {
+	c.Name = name
+	start := time.Now()
+	defer func() {
+		c.Latency = time.Since(start)
+		if p := recover(); p != nil {
+			c.Err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	c.Err = fn()
+	return c
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// LockInfo
+// 当前持有的锁，Do 会把它放到传给fn的ctx中，HeldLocks 也会返回它
+type LockInfo struct {
+	Key    string
+	UniqID string
+	// 写锁的过期时间（秒），只有 Do 会设置
+	Expire int64
+	// 以下只有 HeldLocks 会设置
+	Write bool
+	// 读锁的重入次数，写锁为1
+	Count int
+	// 第一次加锁成功的时间
+	Since time.Time
+}
+
+// ctx中存放 LockInfo 的key
+type lockInfoKey struct{}
+
+// ctx中存放排队位置回调的key
+type queuePositionKey struct{}
+
+// OnQueuePosition
+// 返回带有排队位置回调的ctx，传给 LockUntil / Do 等带ctx的写锁方法
+// 等待写锁期间，每当自己在等待队列中的位置（从1开始）变化时调用fn
+// 只有设置了回调的加锁才会让脚本计算位置，不会给其他调用方增加开销
+func OnQueuePosition(ctx context.Context, fn func(pos int)) context.Context {
+	return context.WithValue(ctx, queuePositionKey{}, fn)
+}
+
+// FromContext
+// 取出 Do 放到ctx中的锁信息
+func FromContext(ctx context.Context) (LockInfo, bool) {
+	info, ok := ctx.Value(lockInfoKey{}).(LockInfo)
+	return info, ok
+}
+
+// Do
+// 加写锁后执行fn，fn返回后释放写锁
+// fn收到的ctx中带有当前锁的信息，可以通过 FromContext 取出
+func Do(ctx context.Context, key, uniqID string, expireTime int64, fn func(ctx context.Context) error) error {
+	return std.Do(ctx, key, uniqID, expireTime, fn)
+}
+
+// Do
+// 同 Do
+func (c *Client) Do(ctx context.Context, key, uniqID string, expireTime int64, fn func(ctx context.Context) error) error {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return err
+	}
+	if err := c.acquire(ctx, key, uniqID, LockCmd, expireTime); err != nil {
+		return err
+	}
+	defer c.Unlock(key, uniqID)
+
+	info := LockInfo{Key: key, UniqID: uniqID, Expire: expireTime}
+	return fn(context.WithValue(ctx, lockInfoKey{}, info))
+}
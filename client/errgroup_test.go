@@ -0,0 +1,85 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+	"golang.org/x/sync/errgroup"
+)
+
+// 每个worker锁一个key，一个worker失败后，还在等锁的worker随ctx取消立即返回，已经拿到的锁都被释放
+func TestErrgroupCancelsWaiters(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	const workers = 4
+	// 最后一个key被别人占着，它的worker会一直等待
+	blocked := "group:" + strconv.Itoa(workers-1)
+	c.Lock(blocked, "other", 30)
+
+	errWork := errors.New("work failed")
+	waiterErr := make(chan error, 1)
+	g, ctx := errgroup.WithContext(context.Background())
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		key := "group:" + strconv.Itoa(i)
+		i := i
+		g.Go(func() error {
+			h, err := c.Acquire(ctx, key, "worker", 10)
+			if key == blocked {
+				waiterErr <- err
+			}
+			if err != nil {
+				return err
+			}
+			defer h.Release()
+			if i == 1 {
+				return errWork
+			}
+			<-ctx.Done()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != errWork {
+		t.Fatalf("Wait = %v; want the failing worker's error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("the group took %v to cancel", elapsed)
+	}
+	if err := <-waiterErr; err != context.Canceled {
+		t.Fatalf("blocked worker err = %v; want context.Canceled", err)
+	}
+	if held := c.HeldLocks(); len(held) != 1 || held[0].UniqID != "other" {
+		t.Fatalf("HeldLocks = %+v; want only the lock held by other", held)
+	}
+	for i := 0; i < workers-1; i++ {
+		key := "group:" + strconv.Itoa(i)
+		if st, err := c.Status(context.Background(), key); err != nil || st.Owner != "" {
+			t.Fatalf("%s after the group = %+v, %v; want free", key, st, err)
+		}
+	}
+	if waiters, err := c.WaiterCount(blocked); err != nil || waiters != 0 {
+		t.Fatalf("waiters on %s = %d, %v; want the cancelled worker gone from the queue", blocked, waiters, err)
+	}
+}
+
+// 和README中的用法相同
+func Example_errgroup() {
+	g, ctx := errgroup.WithContext(context.Background())
+	for _, key := range []string{"order:1", "order:2"} {
+		key := key
+		g.Go(func() error {
+			h, err := client.Acquire(ctx, key, "worker-1", 10)
+			if err != nil {
+				return err
+			}
+			defer h.Release()
+			return nil
+		})
+	}
+	_ = g.Wait()
+}
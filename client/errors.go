@@ -0,0 +1,142 @@
+package client
+
+import "errors"
+
+// Error
+// 包中返回的哨兵错误，实现了 Temporary，通用的重试库可以不比较错误信息就判断是否值得重试
+// 网络超时、锁被占用一类重试可能成功的为临时错误；持有者不匹配、配置和参数错误、脚本错误等重试也不会成功的不是
+type Error struct {
+	msg       string
+	temporary bool
+}
+
+// newError
+// 重试也不会成功的错误
+func newError(msg string) *Error {
+	return &Error{msg: msg}
+}
+
+// newTemporaryError
+// 稍后重试可能成功的错误
+func newTemporaryError(msg string) *Error {
+	return &Error{msg: msg, temporary: true}
+}
+
+// Error
+// 实现error
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Temporary
+// 同一个调用稍后重试是否有可能成功
+func (e *Error) Temporary() bool {
+	return e.temporary
+}
+
+// IsTemporary
+// err或它包装的错误实现了Temporary并返回true，go-redis返回的网络错误（net.Error）和ctx超时同样适用
+func IsTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// ErrNotInitialized
+// 还没有初始化redis客户端就开始使用锁
+var ErrNotInitialized = newError("rwlock is not initialized, call rwlock.Init first")
+
+// ErrInvalidConfig
+// 初始化时的options组合在一起不一致，错误信息中列出了全部问题
+var ErrInvalidConfig = newError("invalid rwlock config")
+
+// ErrDraining
+// 客户端处于排空状态，不再接受新的加锁
+var ErrDraining = newError("rwlock is draining")
+
+// ErrTooManyLocks
+// 持有的锁已经达到 WithMaxHeldLocks 设置的上限
+var ErrTooManyLocks = newTemporaryError("too many locks held")
+
+// ErrAcquireTimeout
+// 加锁重试的总时长超过了 WithMaxAcquireDuration 的限制
+var ErrAcquireTimeout = newTemporaryError("acquire timeout")
+
+// ErrCancelled
+// 等待锁的过程中stop channel被关闭
+var ErrCancelled = newError("acquire cancelled")
+
+// ErrMaxAttempts
+// 加锁的尝试次数达到了 AcquirePolicy.MaxAttempts（或 WaitPolicy.MaxAttempts）
+var ErrMaxAttempts = newTemporaryError("max acquire attempts exceeded")
+
+// ErrLockLost
+// 锁已经过期或者被别人持有
+var ErrLockLost = newError("lock lost")
+
+// ErrLockExpired
+// 释放时锁已经过期或者被别人持有，说明持有锁的时间超过了过期时间
+var ErrLockExpired = newError("lock expired before unlock")
+
+// ErrUpgradeDeadlock
+// 另一个读者已经在阻塞升级，两者互相等待会形成死锁
+var ErrUpgradeDeadlock = newError("upgrade deadlock")
+
+// ErrSelfDeadlock
+// 本进程已经用同一个uniqID持有这把锁的另一种模式，继续等待会自己等自己
+var ErrSelfDeadlock = newError("self deadlock")
+
+// ErrLeaseTooShort
+// 能拿到的锁的过期时间短于 LockMinTTL 要求的最短时间
+var ErrLeaseTooShort = newError("lease shorter than min ttl")
+
+// ErrInvalidOwner
+// 不是 OwnerInfo.Encode 生成的uniqID
+var ErrInvalidOwner = newError("invalid owner info")
+
+// ErrMetadataTooLarge
+// 锁的元数据超过了 MaxMetadataSize
+var ErrMetadataTooLarge = newError("lock metadata too large")
+
+// ErrInvalidExpire
+// 过期时间超过了 MaxExpire
+var ErrInvalidExpire = newError("invalid expire time")
+
+// ErrMalformedReply
+// 脚本的返回值无法解析，通常是脚本被替换成了错误的版本
+var ErrMalformedReply = newError("malformed lua reply")
+
+// ErrEmptyScript
+// 没有读取到Lua脚本的内容
+var ErrEmptyScript = newError("lua script is empty")
+
+// ErrScriptHash
+// SCRIPT LOAD返回的hash为空或者和脚本内容的SHA1不一致
+var ErrScriptHash = newError("unexpected lua script hash")
+
+// ErrEvictionPolicy
+// redis的maxmemory-policy不是noeviction，锁的key可能被淘汰
+var ErrEvictionPolicy = newError("redis maxmemory-policy may evict lock keys")
+
+// ErrForbiddenOwner
+// 锁正被 LockUnless 指定的持有者占用
+var ErrForbiddenOwner = newTemporaryError("held by forbidden owner")
+
+// ErrInCooldown
+// 锁还在 UnlockWithCooldown 设置的冷却期内，放弃等待的加锁返回
+var ErrInCooldown = newTemporaryError("lock is cooling down")
+
+// ErrNoQuorum
+// Redlock 没有在超过半数的节点上完成加锁或释放
+var ErrNoQuorum = newTemporaryError("redlock quorum not reached")
+
+// ErrWouldExtend
+// Shorten 传入的时间不短于锁当前的剩余时间
+var ErrWouldExtend = newError("shorten would extend")
+
+// ErrInvalidKey
+// KB 构造的 Key 不合法
+var ErrInvalidKey = newError("invalid lock key")
+
+// ErrTraceIDTooLarge
+// WithTraceID 设置的trace ID超过了 MaxTraceIDSize
+var ErrTraceIDTooLarge = newError("lock trace id too large")
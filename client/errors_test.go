@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net"
+	"testing"
+)
+
+// 每个哨兵错误的分类
+var temporaryErrors = map[string]struct {
+	err       error
+	temporary bool
+}{
+	"ErrNotInitialized":   {ErrNotInitialized, false},
+	"ErrInvalidConfig":    {ErrInvalidConfig, false},
+	"ErrDraining":         {ErrDraining, false},
+	"ErrTooManyLocks":     {ErrTooManyLocks, true},
+	"ErrAcquireTimeout":   {ErrAcquireTimeout, true},
+	"ErrCancelled":        {ErrCancelled, false},
+	"ErrMaxAttempts":      {ErrMaxAttempts, true},
+	"ErrLockLost":         {ErrLockLost, false},
+	"ErrLockExpired":      {ErrLockExpired, false},
+	"ErrUpgradeDeadlock":  {ErrUpgradeDeadlock, false},
+	"ErrSelfDeadlock":     {ErrSelfDeadlock, false},
+	"ErrLeaseTooShort":    {ErrLeaseTooShort, false},
+	"ErrInvalidOwner":     {ErrInvalidOwner, false},
+	"ErrMetadataTooLarge": {ErrMetadataTooLarge, false},
+	"ErrInvalidExpire":    {ErrInvalidExpire, false},
+	"ErrMalformedReply":   {ErrMalformedReply, false},
+	"ErrEmptyScript":      {ErrEmptyScript, false},
+	"ErrScriptHash":       {ErrScriptHash, false},
+	"ErrEvictionPolicy":   {ErrEvictionPolicy, false},
+	"ErrForbiddenOwner":   {ErrForbiddenOwner, true},
+	"ErrInCooldown":       {ErrInCooldown, true},
+	"ErrNoQuorum":         {ErrNoQuorum, true},
+	"ErrWouldExtend":      {ErrWouldExtend, false},
+	"ErrInvalidKey":       {ErrInvalidKey, false},
+	"ErrTraceIDTooLarge":  {ErrTraceIDTooLarge, false},
+}
+
+func TestTemporarySentinels(t *testing.T) {
+	for name, tt := range temporaryErrors {
+		if got := IsTemporary(tt.err); got != tt.temporary {
+			t.Errorf("IsTemporary(%s) = %t; want %t", name, got, tt.temporary)
+		}
+		var e *Error
+		if !errors.As(tt.err, &e) {
+			t.Errorf("%s is not a *Error", name)
+		}
+	}
+}
+
+// 新增的哨兵错误必须在上面分类
+func TestTemporaryCoversAllSentinels(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				if _, ok := temporaryErrors[name.Name]; !ok {
+					t.Errorf("%s is not classified in temporaryErrors", name.Name)
+				}
+			}
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"wrapped temporary", fmt.Errorf("%w: 3 attempts in 1s", ErrMaxAttempts), true},
+		{"wrapped permanent", fmt.Errorf("%w: reply=%q", ErrMalformedReply, "x"), false},
+		{"net.Error", &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, true},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"wrapped deadline exceeded", fmt.Errorf("acquire: %w", context.DeadlineExceeded), true},
+		{"canceled", context.Canceled, false},
+		{"plain error", errors.New("held by someone else"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTemporary(tt.err); got != tt.want {
+			t.Errorf("IsTemporary(%s) = %t; want %t", tt.name, got, tt.want)
+		}
+	}
+}
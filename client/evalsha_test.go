@@ -0,0 +1,228 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// fakeRedis
+// 按顺序返回预设的回馈，记录每次调用的参数
+type fakeRedis struct {
+	replies []fakeReply
+	calls   []fakeCall
+}
+
+type fakeReply struct {
+	ret interface{}
+	err error
+}
+
+type fakeCall struct {
+	keys []string
+	args []interface{}
+}
+
+func (f *fakeRedis) evalSha(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+	f.calls = append(f.calls, fakeCall{keys: keys, args: args})
+	if len(f.replies) == 0 {
+		return nil, errors.New("fake: no more replies")
+	}
+	r := f.replies[0]
+	f.replies = f.replies[1:]
+	return r.ret, r.err
+}
+
+// count
+// 指令lockCmd被调用的次数
+func (f *fakeRedis) count(lockCmd string) int {
+	n := 0
+	for _, call := range f.calls {
+		if call.keys[1] == lockCmd {
+			n++
+		}
+	}
+	return n
+}
+
+const (
+	busyReply = `{"opRet":false,"status":"busy","owner":"other"}`
+	okReply   = `{"opRet":true,"status":"ok","owner":"a"}`
+)
+
+func newFakeClient(t *testing.T, f *fakeRedis, options ...client.Option) *client.Client {
+	t.Helper()
+	options = append([]client.Option{client.WithBackoffBounds(time.Millisecond, 2*time.Millisecond)}, options...)
+	c, err := client.NewClientWithEvalSha(f.evalSha, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func writeSpec(key, token string) client.LockSpec {
+	return client.LockSpec{Type: client.LockTypeWrite, Key: key, Token: token, TTL: 5 * time.Second}
+}
+
+func TestEvalShaLockRetriesUntilFree(t *testing.T) {
+	f := &fakeRedis{replies: []fakeReply{{ret: busyReply}, {ret: busyReply}, {ret: okReply}}}
+	c := newFakeClient(t, f)
+
+	c.Lock("order:1", "a", 5)
+	if len(f.calls) != 3 {
+		t.Fatalf("calls = %d; want 3", len(f.calls))
+	}
+	call := f.calls[0]
+	if call.keys[0] != "order:1" || call.keys[1] != client.LockCmd {
+		t.Fatalf("keys = %v; want [order:1 LOCK ...]", call.keys)
+	}
+	if call.args[0] != "a" || call.args[1] != "5" {
+		t.Fatalf("args = %v; want [a 5 ...]", call.args)
+	}
+	if held := c.HeldLocks(); len(held) != 1 || held[0].Key != "order:1" {
+		t.Fatalf("HeldLocks = %+v; want order:1", held)
+	}
+}
+
+func TestEvalShaRetriesNetworkError(t *testing.T) {
+	f := &fakeRedis{replies: []fakeReply{{err: errors.New("dial tcp: i/o timeout")}, {ret: okReply}}}
+	c := newFakeClient(t, f)
+
+	if err := c.AcquireWith(context.Background(), writeSpec("order:1", "a"), client.AcquirePolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.calls) != 2 {
+		t.Fatalf("calls = %d; want 2", len(f.calls))
+	}
+}
+
+func TestEvalShaMaxAttempts(t *testing.T) {
+	f := &fakeRedis{replies: []fakeReply{{ret: busyReply}, {ret: busyReply}, {ret: okReply}}}
+	c := newFakeClient(t, f)
+
+	err := c.AcquireWith(context.Background(), writeSpec("order:1", "a"), client.AcquirePolicy{MaxAttempts: 2})
+	if !errors.Is(err, client.ErrMaxAttempts) {
+		t.Fatalf("err = %v; want ErrMaxAttempts", err)
+	}
+	if n := f.count(client.LockCmd); n != 2 {
+		t.Fatalf("LOCK calls = %d; want 2", n)
+	}
+	// 最后一次尝试带上标记，脚本失败时直接离开等待队列，不再单独发LEAVE
+	if last := f.calls[1].args; len(last) != 10 || last[9] != "1" {
+		t.Fatalf("last attempt args = %v; want ARGV[10]=1", last)
+	}
+	if f.calls[0].args[len(f.calls[0].args)-1] == "1" {
+		t.Fatalf("first attempt args = %v; want no last attempt flag", f.calls[0].args)
+	}
+	if n := f.count(client.LeaveCmd); n != 0 {
+		t.Fatalf("LEAVE calls = %d; want 0", n)
+	}
+}
+
+func TestEvalShaMalformedReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		raw   string
+	}{
+		{"invalid json", `{"opRet":tru`, `reply="{\"opRet\":tru"`},
+		{"not a string", []interface{}{int64(1)}, "[]interface {}{1}"},
+		{"unknown integer", int64(7), "7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeRedis{replies: []fakeReply{{ret: tt.reply}, {ret: okReply}}}
+			c := newFakeClient(t, f)
+
+			err := c.AcquireWith(context.Background(), writeSpec("order:1", "a"), client.AcquirePolicy{})
+			if !errors.Is(err, client.ErrMalformedReply) {
+				t.Fatalf("err = %v; want ErrMalformedReply", err)
+			}
+			if !strings.Contains(err.Error(), tt.raw) {
+				t.Fatalf("err = %v; want it to contain the raw reply %s", err, tt.raw)
+			}
+			if n := f.count(client.LockCmd); n != 1 {
+				t.Fatalf("LOCK calls = %d; want no retry", n)
+			}
+		})
+	}
+}
+
+func TestEvalShaErrorReply(t *testing.T) {
+	f := &fakeRedis{replies: []fakeReply{{ret: `{"opRet":false,"status":"error","errMsg":"upgrade deadlock"}`}}}
+	c := newFakeClient(t, f)
+
+	if err := c.Upgrade(context.Background(), "order:1", "a", 5); err != client.ErrUpgradeDeadlock {
+		t.Fatalf("err = %v; want ErrUpgradeDeadlock", err)
+	}
+	if f.calls[0].keys[1] != client.UpgradeCmd {
+		t.Fatalf("keys = %v; want UPGRADE", f.calls[0].keys)
+	}
+}
+
+func TestEvalShaCompactReply(t *testing.T) {
+	f := &fakeRedis{replies: []fakeReply{{ret: int64(0)}, {ret: int64(1)}}}
+	c := newFakeClient(t, f, client.WithCompactReply(true))
+
+	c.Lock("order:1", "a", 5)
+	if len(f.calls) != 2 {
+		t.Fatalf("calls = %d; want 2", len(f.calls))
+	}
+	for _, call := range f.calls {
+		if len(call.keys) < 3 || call.keys[2] != "int" {
+			t.Fatalf("keys = %v; want the compact reply flag", call.keys)
+		}
+	}
+}
+
+func TestEvalShaNoScriptReloads(t *testing.T) {
+	_, m, _ := rwlocktest.NewTestServer(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer rdb.Close()
+
+	// 第一次执行前清空脚本，redis返回真实的NOSCRIPT，之后透传
+	var calls int32
+	evalSha := func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			if err := rdb.ScriptFlush(ctx).Err(); err != nil {
+				return nil, err
+			}
+		}
+		return rdb.EvalSha(ctx, sha, keys, args...).Result()
+	}
+	c, err := client.NewClient(&redis.Options{Addr: m.Addr()},
+		client.WithEvictionCheck(client.EvictionIgnore), client.WithEvalSha(evalSha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.Lock("order:1", "a", 5)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("calls = %d; want 2 (NOSCRIPT, then success after reload)", n)
+	}
+	st, err := c.Status(context.Background(), "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Owner != "a" {
+		t.Fatalf("owner = %q; want a", st.Owner)
+	}
+}
+
+func TestEvalShaNotInitialized(t *testing.T) {
+	c, err := client.NewClientWithEvalSha(nil)
+	if err == nil {
+		_, err = c.TryLock("order:1", "a", 5, 0)
+	}
+	if err == nil {
+		t.Fatal("TryLock without an executor succeeded")
+	}
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// 事件类型
+const (
+	EventAcquired = "acquired"
+	EventReleased = "released"
+)
+
+// 事件中元数据的最大长度（字节），超出部分截断，避免事件过大
+const MaxEventMetadataSize = 256
+
+// Event
+// 写锁的加锁和释放事件，Metadata为加锁时通过 LockWithMeta 附带的元数据
+type Event struct {
+	Type   string
+	Key    string
+	UniqID string
+	// 超过 MaxEventMetadataSize 时被截断
+	Metadata string
+	// 元数据是否被截断
+	Truncated bool
+	// 加锁调用的ID，同一次调用的所有重试共用，和日志、AcquireMetrics 中的一致；释放事件为0
+	AcquireID uint64
+	Time      time.Time
+}
+
+// emitEvent
+// 调用事件回调，没有设置回调时什么都不做
+func (c *Client) emitEvent(typ, key, uniqID, metadata string, acquireID uint64) {
+	if c.conf().onEvent == nil {
+		return
+	}
+	meta, truncated := truncateMetadata(metadata, MaxEventMetadataSize)
+	c.conf().onEvent(Event{
+		Type:      typ,
+		Key:       key,
+		UniqID:    uniqID,
+		Metadata:  meta,
+		Truncated: truncated,
+		AcquireID: acquireID,
+		Time:      time.Now(),
+	})
+}
+
+// truncateMetadata
+// 把元数据截断到max字节以内，不会截断在一个utf8字符的中间
+func truncateMetadata(metadata string, max int) (string, bool) {
+	if len(metadata) <= max {
+		return metadata, false
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(metadata[n]) {
+		n--
+	}
+	return metadata[:n], true
+}
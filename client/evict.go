@@ -0,0 +1,19 @@
+package client
+
+import "context"
+
+// EvictReader
+// 管理操作：强制移除key的一个读者（带uniqID的读锁或按权重的读锁），不管它重入了多少次，返回读者是否存在
+// 移除后如果已经没有读者，会通知等待的写者立即重试
+// 被移除的读者自己并不知道，仍然会以为自己持有读锁，可能和随后拿到写锁的写者同时访问资源；
+// 只用于处理确定已经失控的读者（例如进程卡死但读锁一直被续期）
+// 匿名读锁和 WithSharedReadTTL 模式下的读者只有计数没有ID，无法单独移除
+func EvictReader(key, uniqID string) (bool, error) {
+	return std.EvictReader(key, uniqID)
+}
+
+// EvictReader
+// 同 EvictReader
+func (c *Client) EvictReader(key, uniqID string) (bool, error) {
+	return c.sendOnce(context.Background(), key, uniqID, EvictReaderCmd, 0)
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// 淘汰策略检查的处理方式
+type EvictionCheck int
+
+const (
+	// 只打印警告（默认）
+	EvictionWarn EvictionCheck = iota
+	// 初始化返回 ErrEvictionPolicy
+	EvictionError
+	// 不检查
+	EvictionIgnore
+)
+
+// 不会淘汰key的策略
+const noEvictionPolicy = "noeviction"
+
+// EvictionPolicy
+// 返回初始化时查询到的redis maxmemory-policy，无法查询（如云厂商禁用了CONFIG）时为空
+func EvictionPolicy() string {
+	return std.EvictionPolicy()
+}
+
+// EvictionPolicy
+// 同 EvictionPolicy
+func (c *Client) EvictionPolicy() string {
+	return c.evictionPolicy
+}
+
+// checkEviction
+// 检查redis的淘汰策略
+// 非noeviction的策略在内存不足时可能淘汰锁的key，导致互斥失效
+func (c *Client) checkEviction(ctx context.Context, rdb redis.UniversalClient) error {
+	c.evictionPolicy = ""
+	if c.conf().evictionCheck == EvictionIgnore {
+		return nil
+	}
+	ret, err := rdb.ConfigGet(ctx, "maxmemory-policy").Result()
+	if err != nil || len(ret) < 2 {
+		c.conf().logger.Printf("can not get redis maxmemory-policy: %v", err)
+		return nil
+	}
+	policy, _ := ret[1].(string)
+	c.evictionPolicy = policy
+	if policy == noEvictionPolicy {
+		return nil
+	}
+	if c.conf().evictionCheck == EvictionError {
+		return fmt.Errorf("%w: %s", ErrEvictionPolicy, policy)
+	}
+	c.conf().logger.Printf("redis maxmemory-policy is %s, lock keys may be evicted under memory pressure", policy)
+	return nil
+}
@@ -0,0 +1,91 @@
+package client_test
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+func TestExpireBounds(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	maxTTL := time.Duration(client.MaxExpire) * time.Second
+	defTTL := time.Duration(client.DefaultLockExpire) * time.Second
+	tests := []struct {
+		expire int64
+		// 为0时要求返回 ErrInvalidExpire
+		ttl time.Duration
+	}{
+		{math.MaxInt64, 0},
+		{math.MaxInt32 + 1, 0},
+		{client.MaxExpire + 1, 0},
+		{client.MaxExpire, maxTTL},
+		{1, time.Second},
+		{0, defTTL},
+		{-2, defTTL},
+		{math.MinInt64, defTTL},
+		{client.NoExpire, -1},
+	}
+	for i, tt := range tests {
+		key := "expire:" + strconv.Itoa(i)
+		ok, err := c.TryLock(key, "a", tt.expire, 0)
+		if tt.ttl == 0 {
+			if err != client.ErrInvalidExpire {
+				t.Errorf("TryLock(expire %d) = %v, %v; want ErrInvalidExpire", tt.expire, ok, err)
+			}
+			continue
+		}
+		if err != nil || !ok {
+			t.Errorf("TryLock(expire %d) = %v, %v; want true, nil", tt.expire, ok, err)
+			continue
+		}
+		st, err := c.Status(context.Background(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.ttl < 0 {
+			if st.TTL != -1 {
+				t.Errorf("expire %d: ttl = %v; want -1", tt.expire, st.TTL)
+			}
+		} else if st.TTL <= tt.ttl-time.Second || st.TTL > tt.ttl {
+			t.Errorf("expire %d: ttl = %v; want about %v", tt.expire, st.TTL, tt.ttl)
+		}
+	}
+}
+
+func TestSpecTTLBounds(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	ctx := context.Background()
+	for _, ttl := range []time.Duration{math.MaxInt64, time.Duration(client.MaxExpire+1) * time.Second} {
+		for _, typ := range []string{client.LockTypeWrite, client.LockTypeRead} {
+			spec := client.LockSpec{Type: typ, Key: "spec", Token: "a", TTL: ttl}
+			if err := c.AcquireWith(ctx, spec, client.AcquirePolicy{}); err != client.ErrInvalidExpire {
+				t.Errorf("AcquireWith(%s, ttl %v) = %v; want ErrInvalidExpire", typ, ttl, err)
+			}
+		}
+	}
+}
+
+// 很远的deadline限制在 MaxExpire，不会溢出
+func TestContextExpireClamped(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(math.MaxInt64))
+	defer cancel()
+	if err := c.LockUntil(ctx, "until", "a"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := c.Status(context.Background(), "until")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxTTL := time.Duration(client.MaxExpire) * time.Second; st.TTL <= maxTTL-time.Second || st.TTL > maxTTL {
+		t.Fatalf("ttl = %v; want about %v", st.TTL, maxTTL)
+	}
+}
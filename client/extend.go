@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Extend
+// 手动续期写锁，只有uniqID仍然持有锁时才会把过期时间重置为expireTime
+// 返回false表示锁已经过期或者被别人持有
+// expireTime传入 NoExpire 时锁改为永不过期
+func Extend(key, uniqID string, expireTime int64) (bool, error) {
+	return std.Extend(key, uniqID, expireTime)
+}
+
+// Extend
+// 同 Extend
+func (c *Client) Extend(key, uniqID string, expireTime int64) (bool, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return false, err
+	}
+	return c.sendOnce(context.Background(), key, uniqID, ExtendCmd, expireTime)
+}
+
+// Shorten
+// 缩短写锁的剩余时间为newTTL，只有uniqID仍然持有锁时才会生效，提前知道会很快完成时用于让崩溃后的锁更早释放
+// 只能缩短：newTTL不短于当前的剩余时间时返回 ErrWouldExtend，延长使用 Extend；永不过期的锁可以缩短为任意时间
+// 返回false表示锁已经过期或者被别人持有；注意 LockWithRenew 的后台续期会在下一次续期时把过期时间恢复
+func Shorten(key, uniqID string, newTTL time.Duration) (bool, error) {
+	return std.Shorten(key, uniqID, newTTL)
+}
+
+// Shorten
+// 同 Shorten
+func (c *Client) Shorten(key, uniqID string, newTTL time.Duration) (bool, error) {
+	if newTTL < time.Millisecond {
+		return false, ErrInvalidExpire
+	}
+	// ARGV[3]：新的剩余时间（毫秒）
+	return c.sendOnce(context.Background(), key, uniqID, ShortenCmd, 0, strconv.FormatInt(newTTL.Milliseconds(), 10))
+}
+
+// RRefresh
+// 延长读者的过期时间，只有uniqID的读者还没过期时才会成功
+// 长时间持有读锁的读者可以定期调用，避免释放再重新加锁时读者数量短暂归零让写者插进来
+// expireTime小于等于0时使用配置的读锁过期时间
+func RRefresh(key, uniqID string, expireTime int64) (bool, error) {
+	return std.RRefresh(key, uniqID, expireTime)
+}
+
+// RRefresh
+// 同 RRefresh
+func (c *Client) RRefresh(key, uniqID string, expireTime int64) (bool, error) {
+	if expireTime <= 0 {
+		expireTime = c.conf().readExpire
+	}
+	if expireTime > MaxExpire {
+		return false, ErrInvalidExpire
+	}
+	_, _, rextendCmd := c.readCmds()
+	return c.sendOnce(context.Background(), key, uniqID, rextendCmd, expireTime)
+}
+
+// Reacquire
+// 进程重启后重新获取自己之前持有的锁，只尝试一次
+// 锁仍由nodeID持有时续期并返回true；锁空闲时加锁并返回true；被其他节点持有时返回false
+// nodeID必须是稳定且唯一的节点标识（如StatefulSet的pod名称或配置的节点ID），
+// 重启前后保持不变，并且不能和其他进程重复，否则会把别人的锁当成自己的
+func Reacquire(key, nodeID string, expireTime int64) (bool, error) {
+	return std.Reacquire(key, nodeID, expireTime)
+}
+
+// Reacquire
+// 同 Reacquire
+func (c *Client) Reacquire(key, nodeID string, expireTime int64) (bool, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return false, err
+	}
+	return c.sendOnce(context.Background(), key, nodeID, ReacquireCmd, expireTime)
+}
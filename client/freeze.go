@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// FreezeReaders 默认的冻结时间（秒）
+const DefaultFreezeTTL int64 = 600
+
+// FreezeReaders
+// 维护时冻结key的新读者：freeze为true时，新的 RLock 等加读锁的调用一直等待，已经持有读锁的读者不受影响，
+// 写锁也不受影响；freeze为false时解除冻结，等待的读者立即重试
+// 冻结的时间为 DefaultFreezeTTL，到期自动解除，避免操作者崩溃后一直冻结；维护时间更长时用 FreezeReadersFor 或重复调用
+// 配合 WaitFullyFree 可以等读者自然排空后再维护
+func FreezeReaders(key string, freeze bool) error {
+	return std.FreezeReaders(key, freeze)
+}
+
+// FreezeReaders
+// 同 FreezeReaders
+func (c *Client) FreezeReaders(key string, freeze bool) error {
+	if !freeze {
+		_, err := c.sendOnce(context.Background(), key, "", FreezeCmd, 0, "0")
+		return err
+	}
+	return c.FreezeReadersFor(key, time.Duration(DefaultFreezeTTL)*time.Second)
+}
+
+// FreezeReadersFor
+// 同 FreezeReaders(key, true)，冻结的时间为ttl，向上取整到秒
+func FreezeReadersFor(key string, ttl time.Duration) error {
+	return std.FreezeReadersFor(key, ttl)
+}
+
+// FreezeReadersFor
+// 同 FreezeReadersFor
+func (c *Client) FreezeReadersFor(key string, ttl time.Duration) error {
+	secs := int64(math.Ceil(ttl.Seconds()))
+	if secs <= 0 || secs > MaxExpire {
+		return ErrInvalidExpire
+	}
+	_, err := c.sendOnce(context.Background(), key, "", FreezeCmd, secs, "1")
+	return err
+}
+
+// WaitFullyFree
+// 等待key既没有写锁也没有读者，ctx取消时返回ctx.Err()
+// 只是观察，不会阻止别人加锁，返回后马上就可能又被加锁；需要阻止新的读者时先调用 FreezeReaders
+func WaitFullyFree(ctx context.Context, key string) error {
+	return std.WaitFullyFree(ctx, key)
+}
+
+// WaitFullyFree
+// 同 WaitFullyFree
+func (c *Client) WaitFullyFree(ctx context.Context, key string) error {
+	var wakeup <-chan struct{}
+	if c.conf().notify {
+		ch, cancel := c.notify.register(key)
+		defer cancel()
+		wakeup = ch
+	}
+	for {
+		st, err := c.Status(ctx, key)
+		if err != nil {
+			return err
+		}
+		if len(st.Owner) <= 0 && st.Readers <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(c.getRandomSleepTime())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-wakeup:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// keyGate
+// 按key限制本进程同时进行的加锁尝试，超出的在本地排队，按到达的顺序进入
+type keyGate struct {
+	mu    sync.Mutex
+	gates map[string]*gateEntry
+}
+
+type gateEntry struct {
+	slots chan struct{}
+	// 正在使用和等待的协程数，为0时删除
+	refs int
+}
+
+// newKeyGate
+// 创建空的限流器
+func newKeyGate() *keyGate {
+	return &keyGate{gates: make(map[string]*gateEntry)}
+}
+
+// enter
+// 等待key的一个名额，返回归还名额的函数
+// ctx取消时返回ctx.Err()；到达deadline时不占用名额直接返回，由调用方按加锁超时处理
+func (g *keyGate) enter(ctx context.Context, key string, limit int, deadline time.Time) (leave func(), err error) {
+	g.mu.Lock()
+	e, ok := g.gates[key]
+	if !ok {
+		e = &gateEntry{slots: make(chan struct{}, limit)}
+		g.gates[key] = e
+	}
+	e.refs++
+	g.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case e.slots <- struct{}{}:
+		return func() {
+			<-e.slots
+			g.release(key, e)
+		}, nil
+	case <-ctx.Done():
+		g.release(key, e)
+		return nil, ctx.Err()
+	case <-timeout:
+		g.release(key, e)
+		return func() {}, nil
+	}
+}
+
+func (g *keyGate) release(key string, e *gateEntry) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if e.refs--; e.refs <= 0 {
+		delete(g.gates, key)
+	}
+}
@@ -0,0 +1,40 @@
+package client_test
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+)
+
+// 本进程大量协程争抢同一个key时，限制同时进行的加锁尝试可以减少访问redis的次数
+func TestMaxInflightAcquiresReducesCalls(t *testing.T) {
+	t.Parallel()
+	contend := func(options ...client.Option) int64 {
+		options = append(options, client.WithBackoffBounds(time.Millisecond, 2*time.Millisecond))
+		c, _, calls := countingServer(t, options...)
+		var wg sync.WaitGroup
+		for i := 0; i < 32; i++ {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				c.Lock("hot", id, 5)
+				time.Sleep(2 * time.Millisecond)
+				c.Unlock("hot", id)
+			}("w" + strconv.Itoa(i))
+		}
+		wg.Wait()
+		return atomic.LoadInt64(calls)
+	}
+
+	free := contend()
+	gated := contend(client.WithMaxInflightAcquires(1))
+	t.Logf("EvalSha calls: %d without the limit, %d with WithMaxInflightAcquires(1)", free, gated)
+	// 限制为1时只有一个协程在重试，其他协程在本地排队
+	if gated*2 >= free {
+		t.Fatalf("EvalSha calls = %d with the limit, %d without; want clearly fewer with it", gated, free)
+	}
+}
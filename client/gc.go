@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+)
+
+// GCReaders
+// 清理一个锁下已经过期的读者和不在线的等待者
+// 脚本每次最多清理固定数量的记录，避免长时间阻塞redis，more为true时表示还没有清理完，可以继续调用
+// 等待队列只检查队首的一个窗口，窗口内的等待者都在线时more为false，排在它们后面的等待者随队列前进时再检查
+//
+//	for more := true; more && err == nil; {
+//		more, err = client.GCReaders(ctx, key)
+//	}
+func GCReaders(ctx context.Context, key string) (more bool, err error) {
+	return std.GCReaders(ctx, key)
+}
+
+// GCReaders
+// 同 GCReaders
+func (c *Client) GCReaders(ctx context.Context, key string) (more bool, err error) {
+	res, err := c.sendLock(ctx, key, "", GCCmd, 0)
+	if err != nil {
+		c.handleError(err)
+		return false, err
+	}
+	if res.IsError() {
+		return false, replyError(res)
+	}
+	return res.More, nil
+}
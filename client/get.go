@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// RLockGet
+// 加匿名读锁（同 RLock(key)），并在同一次Lua调用中读取valueKey的值，加锁和读取之间不会有别人写入
+// valueKey不存在时返回空字符串和nil，读锁仍然持有，需要调用 RUnlock(key) 释放
+// 值通过JSON返回，只支持UTF-8文本
+// 集群模式下valueKey必须和锁的key在同一个slot，例如锁的key为"{order:1}"，valueKey为"{order:1}:data"
+func RLockGet(key, valueKey string) (value string, err error) {
+	return std.RLockGet(key, valueKey)
+}
+
+// RLockGet
+// 同 RLockGet
+func (c *Client) RLockGet(key, valueKey string) (value string, err error) {
+	if len(valueKey) <= 0 {
+		return "", errors.New("value key is nil")
+	}
+	res, err := c.acquireReply(context.Background(), key, "", RLockGetCmd, c.conf().readExpire, AcquirePolicy{}, valueKey)
+	if err != nil {
+		return "", err
+	}
+	return res.Value, nil
+}
+
+// LockGet
+// 加写锁（同 Lock），并在同一次Lua调用中读取valueKey的值，限制同 RLockGet
+// 需要调用 Unlock 释放
+func LockGet(key, uniqID, valueKey string, expireTime int64) (value string, err error) {
+	return std.LockGet(key, uniqID, valueKey, expireTime)
+}
+
+// LockGet
+// 同 LockGet
+func (c *Client) LockGet(key, uniqID, valueKey string, expireTime int64) (value string, err error) {
+	if len(valueKey) <= 0 {
+		return "", errors.New("value key is nil")
+	}
+	expireTime, err = c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.acquireReply(context.Background(), key, uniqID, LockGetCmd, expireTime, AcquirePolicy{}, "", "", valueKey)
+	if err != nil {
+		return "", err
+	}
+	return res.Value, nil
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// UnlockGrace
+// 软释放写锁：锁在grace内仍然由uniqID持有，期间uniqID重新加锁立即成功（不排队、不受其他写者影响），
+// 没有重新加锁时锁在grace后自然过期，用于释放后马上又要加锁的循环，避免锁在两次之间被别人拿走
+// 宽限期内其他写者只能等锁过期，不会收到 WithNotify 的释放通知；对本地持有记录、事件来说锁已经释放
+// 宽限期内再调用 Unlock 会立即释放；grace小于1毫秒时等同于 Unlock
+func UnlockGrace(key, uniqID string, grace time.Duration) {
+	std.UnlockGrace(key, uniqID, grace)
+}
+
+// UnlockGrace
+// 同 UnlockGrace
+func (c *Client) UnlockGrace(key, uniqID string, grace time.Duration) {
+	// ARGV[3]、ARGV[4]：释放后的冷却期、软释放的宽限期（毫秒）
+	if err, fatal := c.unlock(context.Background(), key, uniqID, "", strconv.FormatInt(grace.Milliseconds(), 10)); fatal {
+		panic(err)
+	}
+}
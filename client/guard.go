@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// LockGuard
+// 持有中的写锁，由 Guard 返回
+//
+// 生命周期：Guard 加锁成功后即持有锁，之后可以多次 Renew 或者调用 AutoRenew 启动后台续期，
+// 最后调用 Release 释放。Release 可以重复调用，推荐紧跟着 Guard 写 defer g.Release()，
+// 这样fn中途return或者panic时锁也会被释放。Release 之后 Renew 返回false
+// Release 不会panic，释放失败时返回错误，defer中不关心结果时可以忽略
+type LockGuard struct {
+	c      *Client
+	key    string
+	uniqID string
+	expire int64
+
+	mu         sync.Mutex
+	released   bool
+	releaseErr error
+	stop       func()
+	// Release 时关闭，让等待ctx取消的协程退出
+	done chan struct{}
+}
+
+// Guard
+// 加写锁并返回 LockGuard，和 Do 不同，不需要把临界区包成函数
+// 加锁和释放经过 Unlock 等同样的路径，会计入 Drain 的统计
+// 设置了 WithReleaseOnCancel 时，ctx取消后自动 Release
+func Guard(ctx context.Context, key, uniqID string, expireTime int64) (*LockGuard, error) {
+	return std.Guard(ctx, key, uniqID, expireTime)
+}
+
+// Guard
+// 同 Guard
+func (c *Client) Guard(ctx context.Context, key, uniqID string, expireTime int64) (*LockGuard, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.acquire(ctx, key, uniqID, LockCmd, expireTime); err != nil {
+		return nil, err
+	}
+	g := &LockGuard{c: c, key: key, uniqID: uniqID, expire: expireTime, done: make(chan struct{})}
+	if c.conf().releaseOnCancel && ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				// 后台协程中没有人处理错误，释放失败时只打印日志
+				if err := g.Release(); err != nil {
+					logWith(c.conf().logger, Fields{FieldKey: key, FieldOp: UnlockCmd, FieldToken: g.uniqID}).
+						Printf("release lock %s on cancel failed: %v", key, err)
+				}
+			case <-g.done:
+			}
+		}()
+	}
+	return g, nil
+}
+
+// Key
+// 锁的key
+func (g *LockGuard) Key() string {
+	return g.key
+}
+
+// Renew
+// 手动续期，把过期时间重置为加锁时的过期时间
+// 返回false表示锁已经释放、过期或者被别人持有
+func (g *LockGuard) Renew() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.released {
+		return false, nil
+	}
+	return g.c.sendOnce(context.Background(), g.key, g.uniqID, ExtendCmd, g.expire)
+}
+
+// AutoRenew
+// 启动后台续期，规则同 LockWithRenew，Release 时自动停止
+// ctx取消也会停止续期，但不会释放锁；重复调用只会启动一次
+func (g *LockGuard) AutoRenew(ctx context.Context) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.released || g.stop != nil || g.expire == NoExpire {
+		return
+	}
+	g.stop = g.c.startRenew(ctx, g.key, g.uniqID, g.expire)
+}
+
+// Release
+// 停止续期并释放锁，可以重复调用，只有第一次生效，之后返回第一次的结果
+// 错误同 UnlockE：网络错误重试用完后返回最后一次的错误，锁已经过期时按 WithUnlockExpiredPolicy 处理
+func (g *LockGuard) Release() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.released {
+		return g.releaseErr
+	}
+	g.released = true
+	close(g.done)
+	if g.stop != nil {
+		g.stop()
+	}
+	g.releaseErr = g.c.UnlockE(g.key, g.uniqID)
+	return g.releaseErr
+}
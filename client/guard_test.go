@@ -0,0 +1,36 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lzw5399/rwlock/client"
+)
+
+// 释放失败时 Release 返回错误而不是panic，重复调用返回第一次的结果
+func TestGuardReleaseReturnsError(t *testing.T) {
+	f := &fakeRedis{replies: []fakeReply{{ret: okReply}, {ret: `{"opRet":tru`}}}
+	c := newFakeClient(t, f)
+	g, err := c.Guard(context.Background(), "order:1", "a", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				t.Fatalf("Release panicked: %v", p)
+			}
+		}()
+		if err := g.Release(); !errors.Is(err, client.ErrMalformedReply) {
+			t.Fatalf("Release = %v; want ErrMalformedReply", err)
+		}
+	}()
+	if err := g.Release(); !errors.Is(err, client.ErrMalformedReply) {
+		t.Fatalf("second Release = %v; want the first result", err)
+	}
+	if n := f.count(client.UnlockCmd); n != 1 {
+		t.Fatalf("UNLOCK calls = %d; want 1", n)
+	}
+}
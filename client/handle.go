@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Handle
+// 持有中的锁，由 Acquire、AcquireRead 返回，是最常用的加锁入口；简单的场景仍然可以直接用 Lock/Unlock
+//
+// 可以在多个协程中使用：Release 只有第一次调用会释放锁，同时调用的其他协程等它完成后得到同样的结果；
+// Release 之后 Renew 返回 ErrLockLost，TTL 返回 ErrLockLost，Valid 返回false
+type Handle struct {
+	// 锁的key
+	Key string
+	// 加锁使用的uniqID
+	Token string
+	// 是否是写锁
+	Write bool
+	// 写锁的fencing token，同一个key每次通过 Acquire 加写锁成功都会递增，读锁为0
+	// 写入受保护的资源时带上它，资源方拒绝比见过的更小的token，可以挡住锁过期后还在写的旧持有者
+	Fence int64
+	// 加锁尝试的次数（访问redis的次数）和从调用到加锁成功的时间
+	Attempts   int
+	Waited     time.Duration
+	AcquiredAt time.Time
+	// 加锁调用的ID，和日志、事件中的一致
+	AcquireID uint64
+
+	c *Client
+
+	mu         sync.Mutex
+	released   bool
+	releaseErr error
+}
+
+// acquireStats
+// 加锁循环的统计，通过ctx传给 acquireReply
+type acquireStats struct {
+	attempts  int
+	acquireID uint64
+}
+
+type acquireStatsKey struct{}
+
+// Acquire
+// 加写锁并返回 Handle，同时分配fencing token；ctx取消时返回ctx.Err()
+// fencing token的计数器在redis中永久保存，一个key一个
+func Acquire(ctx context.Context, key, uniqID string, expireTime int64) (*Handle, error) {
+	return std.Acquire(ctx, key, uniqID, expireTime)
+}
+
+// Acquire
+// 同 Acquire
+func (c *Client) Acquire(ctx context.Context, key, uniqID string, expireTime int64) (*Handle, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return nil, err
+	}
+	// ARGV[3]~ARGV[6]：排队位置标记、元数据、值的key、分配fencing token
+	return c.acquireHandle(ctx, key, uniqID, LockCmd, expireTime, true, "", "", "", "1")
+}
+
+// AcquireRead
+// 加读锁并返回 Handle，uniqID不能为空，过期时间为配置的读锁过期时间
+func AcquireRead(ctx context.Context, key, uniqID string) (*Handle, error) {
+	return std.AcquireRead(ctx, key, uniqID)
+}
+
+// AcquireRead
+// 同 AcquireRead
+func (c *Client) AcquireRead(ctx context.Context, key, uniqID string) (*Handle, error) {
+	if len(uniqID) <= 0 {
+		return nil, errors.New("read handle uniqID is nil")
+	}
+	rlockCmd, _, _ := c.readCmds()
+	return c.acquireHandle(ctx, key, uniqID, rlockCmd, c.conf().readExpire, false)
+}
+
+func (c *Client) acquireHandle(ctx context.Context, key, uniqID, lockCmd string, expireTime int64, write bool, extra ...string) (*Handle, error) {
+	stats := &acquireStats{}
+	start := time.Now()
+	ctx = withFullReply(context.WithValue(ctx, acquireStatsKey{}, stats))
+	res, err := c.acquireReply(ctx, key, uniqID, lockCmd, expireTime, AcquirePolicy{}, extra...)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &Handle{
+		Key:        key,
+		Token:      uniqID,
+		Write:      write,
+		Fence:      res.Fence,
+		Attempts:   stats.attempts,
+		Waited:     now.Sub(start),
+		AcquiredAt: now,
+		AcquireID:  stats.acquireID,
+		c:          c,
+	}, nil
+}
+
+// Release
+// 释放锁，可以重复调用，只有第一次生效，之后返回第一次的结果
+// 网络错误重试用完后返回最后一次的错误，这时锁会等到过期才释放
+// 写锁已经过期时按 WithUnlockExpiredPolicy 处理，默认返回nil
+func (h *Handle) Release() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.released {
+		return h.releaseErr
+	}
+	h.released = true
+	if h.Write {
+		h.releaseErr, _ = h.c.unlock(withFullReply(context.Background()), h.Key, h.Token)
+	} else {
+		h.releaseErr = h.c.runlock(h.Key, h.Token)
+	}
+	return h.releaseErr
+}
+
+// Renew
+// 把锁的过期时间重置为ttl，向上取整到秒
+// 锁已经释放、过期或者被别人持有时返回 ErrLockLost
+func (h *Handle) Renew(ttl time.Duration) error {
+	secs := int64(math.Ceil(ttl.Seconds()))
+	if secs <= 0 || secs > MaxExpire {
+		return ErrInvalidExpire
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.released {
+		return ErrLockLost
+	}
+	cmd := ExtendCmd
+	if !h.Write {
+		_, _, cmd = h.c.readCmds()
+	}
+	ok, err := h.c.sendOnce(context.Background(), h.Key, h.Token, cmd, secs)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// TTL
+// 查询锁的剩余时间，-1表示永不过期
+// 锁已经释放、过期或者被别人持有时返回 ErrLockLost
+// 读锁从 Status 列出的读者中查找，读者超过100个时可能找不到自己而返回 ErrLockLost
+func (h *Handle) TTL() (time.Duration, error) {
+	h.mu.Lock()
+	released := h.released
+	h.mu.Unlock()
+	if released {
+		return 0, ErrLockLost
+	}
+	st, err := h.c.Status(context.Background(), h.Key)
+	if err != nil {
+		return 0, err
+	}
+	if h.Write {
+		if st.Owner != h.Token {
+			return 0, ErrLockLost
+		}
+		return st.TTL, nil
+	}
+	for _, r := range st.ReaderList {
+		if r.ID == h.Token {
+			return r.RemainingTTL, nil
+		}
+	}
+	return 0, ErrLockLost
+}
+
+// Valid
+// 锁是否仍然被自己持有，查询失败时返回错误
+func (h *Handle) Valid() (bool, error) {
+	_, err := h.TTL()
+	if err == ErrLockLost {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
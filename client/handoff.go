@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// HandoffTo
+// 同 Handoff，同时把续期的责任交给新的持有者：交接前暂停本客户端对fromUniqID的后台续期，
+// 交接成功后停止续期，失败时恢复续期。锁不是fromUniqID持有时返回 ErrLockLost
+//
+// 用于滚动发布中单例任务的无缝交接，两个进程需要这样配合：
+//  1. 新进程先调用 AcceptHandoff(ctx, key, toUniqID, expireTime) 等待接手，它会在拿到锁的同时开始续期
+//  2. 旧进程停止处理任务后调用 HandoffTo(ctx, key, fromUniqID, toUniqID)，锁的剩余过期时间不变
+//  3. 新进程的 AcceptHandoff 返回后开始处理任务，之后和 LockWithRenew 拿到的锁一样用 Unlock 释放
+//
+// toUniqID需要通过配置或者服务发现事先约定；新进程必须在锁过期前接手，所以过期时间不能短于新进程启动到调用
+// AcceptHandoff 的时间。旧进程在HandoffTo返回前仍然是持有者，返回后不能再访问受保护的资源
+func HandoffTo(ctx context.Context, key, fromUniqID, toUniqID string) error {
+	return std.HandoffTo(ctx, key, fromUniqID, toUniqID)
+}
+
+// HandoffTo
+// 同 HandoffTo
+func (c *Client) HandoffTo(ctx context.Context, key, fromUniqID, toUniqID string) error {
+	if len(toUniqID) <= 0 {
+		return errors.New("handoff target is nil")
+	}
+	r := c.renewals.get(key, fromUniqID)
+	if r != nil {
+		atomic.StoreInt32(&r.paused, 1)
+	}
+	ok, err := c.sendOnce(ctx, key, fromUniqID, HandoffCmd, 0, toUniqID)
+	if r != nil {
+		if ok {
+			r.stop()
+			<-r.done
+		} else {
+			atomic.StoreInt32(&r.paused, 0)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// AcceptHandoff
+// 等待别的进程通过 HandoffTo 把写锁交给uniqID，拿到后立即续期到expireTime并启动后台续期，规则同 LockWithRenew
+// 返回的cancel停止续期但不释放锁；ctx取消时返回ctx.Err()，超过 WithMaxAcquireDuration 时返回 ErrAcquireTimeout
+func AcceptHandoff(ctx context.Context, key, uniqID string, expireTime int64) (cancel func(), err error) {
+	return std.AcceptHandoff(ctx, key, uniqID, expireTime)
+}
+
+// AcceptHandoff
+// 同 AcceptHandoff
+func (c *Client) AcceptHandoff(ctx context.Context, key, uniqID string, expireTime int64) (cancel func(), err error) {
+	if len(uniqID) <= 0 {
+		return nil, errors.New("handoff target is nil")
+	}
+	expireTime, err = c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return nil, err
+	}
+	var deadline time.Time
+	if c.conf().maxAcquireDuration > 0 {
+		deadline = time.Now().Add(c.conf().maxAcquireDuration)
+	}
+	for {
+		// 只有持有者是自己时续期才会成功
+		ok, err := c.sendOnce(ctx, key, uniqID, ExtendCmd, expireTime)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if ok {
+			break
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, ErrAcquireTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.getRandomSleepTime()):
+		}
+	}
+	c.held.removeAll(key, uniqID, true)
+	c.held.add(key, uniqID, true)
+	if expireTime == NoExpire {
+		return func() {}, nil
+	}
+	return c.startRenew(ctx, key, uniqID, expireTime), nil
+}
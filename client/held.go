@@ -0,0 +1,302 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// heldKey
+// 本进程持有的一把锁
+type heldKey struct {
+	key    string
+	uniqID string
+	write  bool
+}
+
+// heldRegistry
+// 本进程持有的锁的记录，只反映本地的认知
+// 锁在redis中过期后这里不会感知，所以只能用于排空、统计等本地判断
+type heldRegistry struct {
+	mu    sync.Mutex
+	locks map[heldKey]*heldEntry
+	// 每次变化时关闭并替换，用于等待变化
+	changed chan struct{}
+}
+
+// heldEntry
+// 一把锁的重入次数和第一次加锁成功的时间
+type heldEntry struct {
+	count int
+	since time.Time
+}
+
+// newHeldRegistry
+// 创建空的记录
+func newHeldRegistry() *heldRegistry {
+	return &heldRegistry{
+		locks:   make(map[heldKey]*heldEntry),
+		changed: make(chan struct{}),
+	}
+}
+
+// add
+// 记录加锁成功，读锁可重入所以按次数累加
+func (h *heldRegistry) add(key, uniqID string, write bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	k := heldKey{key, uniqID, write}
+	e, ok := h.locks[k]
+	if !ok {
+		e = &heldEntry{since: time.Now()}
+		h.locks[k] = e
+	}
+	e.count++
+	h.notifyLocked()
+}
+
+// remove
+// 记录释放，写锁直接删除，读锁减少一次
+func (h *heldRegistry) remove(key, uniqID string, write bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	k := heldKey{key, uniqID, write}
+	e, ok := h.locks[k]
+	if !ok {
+		return
+	}
+	if write || e.count <= 1 {
+		delete(h.locks, k)
+	} else {
+		e.count--
+	}
+	h.notifyLocked()
+}
+
+// removeAll
+// 删除一把锁的全部记录，不管重入了多少次
+func (h *heldRegistry) removeAll(key, uniqID string, write bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.locks[heldKey{key, uniqID, write}]; ok {
+		delete(h.locks, heldKey{key, uniqID, write})
+		h.notifyLocked()
+	}
+}
+
+// reset
+// 清空全部记录
+func (h *heldRegistry) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.locks = make(map[heldKey]*heldEntry)
+	h.notifyLocked()
+}
+
+// size
+// 当前持有的锁的数量，重入的读锁只算一次，返回等待变化的channel
+func (h *heldRegistry) size() (int, <-chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.locks), h.changed
+}
+
+// list
+// 当前持有的锁，按加锁时间从早到晚排列
+func (h *heldRegistry) list() []LockInfo {
+	h.mu.Lock()
+	infos := make([]LockInfo, 0, len(h.locks))
+	for k, e := range h.locks {
+		infos = append(infos, LockInfo{Key: k.key, UniqID: k.uniqID, Write: k.write, Count: e.count, Since: e.since})
+	}
+	h.mu.Unlock()
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].Since.Equal(infos[j].Since) {
+			return infos[i].Since.Before(infos[j].Since)
+		}
+		return infos[i].Key < infos[j].Key
+	})
+	return infos
+}
+
+// exceeds
+// 执行lockCmd会不会让持有的锁超过max把
+// 重入已经持有的锁、升级读锁不会增加数量
+func (h *heldRegistry) exceeds(lockCmd, key, uniqID string, max int) bool {
+	var write bool
+	switch lockCmd {
+	case LockCmd, LockIfCmd, ReacquireCmd, LockGetCmd:
+		write = true
+	case RLockCmd, WRLockCmd, SRLockCmd, RLockGetCmd:
+	default:
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.locks[heldKey{key, uniqID, write}]; ok {
+		return false
+	}
+	return len(h.locks) >= max
+}
+
+// holds
+// 本进程是否持有这把锁
+func (h *heldRegistry) holds(key, uniqID string, write bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.locks[heldKey{key, uniqID, write}]
+	return ok
+}
+
+// holdsAny
+// 本进程是否以任意uniqID持有这把锁
+func (h *heldRegistry) holdsAny(key string, write bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k := range h.locks {
+		if k.key == key && k.write == write {
+			return true
+		}
+	}
+	return false
+}
+
+// selfConflict
+// 阻塞加锁前检查是否和本进程已经持有的锁冲突：持有写锁再加读锁或写锁、持有读锁再加写锁
+// 升级、降级有专门的指令，不在检查范围内；匿名读者无法区分身份，也不检查
+func (h *heldRegistry) selfConflict(lockCmd, key, uniqID string) bool {
+	if len(uniqID) <= 0 {
+		return false
+	}
+	switch lockCmd {
+	case LockCmd, LockGetCmd:
+		return h.holds(key, uniqID, true) || h.holds(key, uniqID, false)
+	case RLockCmd, SRLockCmd, RLockGetCmd:
+		return h.holds(key, uniqID, true)
+	}
+	return false
+}
+
+func (h *heldRegistry) notifyLocked() {
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// track
+// 指令执行成功后更新本地持有的锁的记录
+func (h *heldRegistry) track(lockCmd, key, uniqID string) {
+	switch lockCmd {
+	case LockCmd, LockIfCmd, ReacquireCmd, LockGetCmd:
+		h.removeAll(key, uniqID, true)
+		h.add(key, uniqID, true)
+	case RLockCmd, WRLockCmd, SRLockCmd, RLockGetCmd:
+		h.add(key, uniqID, false)
+	case UpgradeCmd:
+		h.removeAll(key, uniqID, false)
+		h.add(key, uniqID, true)
+	case HandoffCmd:
+		h.removeAll(key, uniqID, true)
+	case WRUnlockCmd:
+		h.remove(key, uniqID, false)
+	}
+}
+
+// isAcquireCmd
+// 是否是获取锁的指令，排空状态下会被拒绝
+func isAcquireCmd(lockCmd string) bool {
+	switch lockCmd {
+	case LockCmd, RLockCmd, WRLockCmd, SRLockCmd, UpgradeCmd, LockIfCmd, ReacquireCmd, RLockGetCmd, LockGetCmd:
+		return true
+	}
+	return false
+}
+
+// Drain
+// 进入排空状态：之后新的加锁立即返回 ErrDraining（无返回值的 Lock/RLock 会panic），
+// Unlock/RUnlock 等释放操作不受影响
+// 用于节点下线前，配合 WaitForDrain 等待已经持有的锁全部释放，重新 DoInit 后恢复
+func Drain() {
+	std.Drain()
+}
+
+// Drain
+// 同 Drain，只影响这个客户端
+func (c *Client) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+// Draining
+// 是否处于排空状态
+func Draining() bool {
+	return std.Draining()
+}
+
+// Draining
+// 同 Draining
+func (c *Client) Draining() bool {
+	return atomic.LoadInt32(&c.draining) == 1
+}
+
+// WaitForDrain
+// 等待本进程持有的锁全部释放，ctx取消时返回ctx.Err()
+// 只统计通过本客户端加锁、还没有通过本客户端释放的锁
+func WaitForDrain(ctx context.Context) error {
+	return std.WaitForDrain(ctx)
+}
+
+// WaitForDrain
+// 同 WaitForDrain，只统计这个客户端持有的锁
+func (c *Client) WaitForDrain(ctx context.Context) error {
+	for {
+		n, changed := c.held.size()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// HeldLocks
+// 默认客户端当前持有的锁，按加锁时间从早到晚排列，用于排查忘记释放的锁，返回的 LockInfo 中没有Expire
+// 只反映本地的记录：锁在redis中过期后仍然会列出，直到调用释放或者后台续期发现锁已经丢失
+func HeldLocks() []LockInfo {
+	return std.HeldLocks()
+}
+
+// HeldLocks
+// 同 HeldLocks
+func (c *Client) HeldLocks() []LockInfo {
+	return c.held.list()
+}
+
+// HoldsWrite
+// 默认客户端是否持有key的写锁（任意uniqID），只查本地的记录，不访问redis
+// 反映的是本地的认知：锁在redis中过期或被接管后仍然返回true，直到释放或者后台续期发现锁已经丢失；
+// 需要确认时用 Status 查看Owner，或者 Handle.Valid
+func HoldsWrite(key string) bool {
+	return std.HoldsWrite(key)
+}
+
+// HoldsWrite
+// 同 HoldsWrite
+func (c *Client) HoldsWrite(key string) bool {
+	return c.held.holdsAny(key, true)
+}
+
+// HoldsRead
+// 默认客户端是否持有key的读锁（任意uniqID），只查本地的记录，不访问redis，注意事项同 HoldsWrite
+func HoldsRead(key string) bool {
+	return std.HoldsRead(key)
+}
+
+// HoldsRead
+// 同 HoldsRead
+func (c *Client) HoldsRead(key string) bool {
+	return c.held.holdsAny(key, false)
+}
@@ -0,0 +1,130 @@
+package client_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+func TestMaxHeldLocks(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t, client.WithMaxHeldLocks(2))
+
+	c.Lock("held:1", "a", 5)
+	c.RLockID("held:2", "a")
+	if _, err := c.TryLock("held:3", "a", 5, 0); err != client.ErrTooManyLocks {
+		t.Fatalf("third lock err = %v; want ErrTooManyLocks", err)
+	}
+	if _, err := c.TryRLock("held:3", "a"); err != client.ErrTooManyLocks {
+		t.Fatalf("third read lock err = %v; want ErrTooManyLocks", err)
+	}
+	// 重入已经持有的锁不受限制
+	if res, err := c.TryRLock("held:2", "a"); err != nil || !res.Acquired {
+		t.Fatalf("reentrant read lock = %+v, %v; want acquired", res, err)
+	}
+	c.RUnlockID("held:2", "a")
+	c.RUnlockID("held:2", "a")
+	if ok, err := c.TryLock("held:3", "a", 5, 0); err != nil || !ok {
+		t.Fatalf("lock after a release = %v, %v; want true, nil", ok, err)
+	}
+}
+
+func TestHeldLocksAccounting(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+
+	c.RLockID("held:r", "a")
+	c.RLockID("held:r", "a")
+	c.Lock("held:w", "a", 5)
+	held := c.HeldLocks()
+	if len(held) != 2 {
+		t.Fatalf("HeldLocks = %+v; want 2 locks", held)
+	}
+	if held[0].Key != "held:r" || held[0].Write || held[0].Count != 2 {
+		t.Fatalf("first lock = %+v; want the reentrant read lock with count 2", held[0])
+	}
+	if held[1].Key != "held:w" || !held[1].Write || held[1].Count != 1 {
+		t.Fatalf("second lock = %+v; want the write lock", held[1])
+	}
+	c.RUnlockID("held:r", "a")
+	if held = c.HeldLocks(); len(held) != 2 || held[0].Count != 1 {
+		t.Fatalf("after one RUnlockID: %+v", held)
+	}
+	c.RUnlockID("held:r", "a")
+	c.Unlock("held:w", "a")
+	if held = c.HeldLocks(); len(held) != 0 {
+		t.Fatalf("after releasing everything: %+v", held)
+	}
+}
+
+// 后台续期的锁在 HeldLocks 中，续期发现锁被接管后移除
+func TestHeldLocksRenewed(t *testing.T) {
+	t.Parallel()
+	lost := make(chan struct{})
+	c, m, _ := rwlocktest.NewTestServer(t, client.WithOnRenewFailed(func(key, uniqID string, err error) {
+		close(lost)
+	}))
+	cancel, err := c.LockWithRenew(context.Background(), "held:renew", "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	if held := c.HeldLocks(); len(held) != 1 || held[0].Key != "held:renew" {
+		t.Fatalf("HeldLocks = %+v; want the renewed lock", held)
+	}
+	// 清空redis，模拟锁过期后被别人拿走
+	m.FlushAll()
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("renewal never noticed the lost lock")
+	}
+	if held := c.HeldLocks(); len(held) != 0 {
+		t.Fatalf("HeldLocks after the lock was lost = %+v; want none", held)
+	}
+}
+
+func TestHeldLocksConcurrent(t *testing.T) {
+	t.Parallel()
+	const workers, rounds, max = 8, 20, 4
+	c, _ := rwlocktest.NewTestClient(t, client.WithMaxHeldLocks(max))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			id := "w" + strconv.Itoa(w)
+			for i := 0; i < rounds; i++ {
+				key := "held:" + id + ":" + strconv.Itoa(i%3)
+				ok, err := c.TryLock(key, id, 5, 0)
+				if err == client.ErrTooManyLocks {
+					continue
+				}
+				if err != nil || !ok {
+					errs <- err
+					continue
+				}
+				// 检查和加锁不是原子的，最多超过上限的并发加锁数
+				if n := len(c.HeldLocks()); n > max+workers {
+					t.Errorf("HeldLocks has %d locks; limit %d", n, max)
+				}
+				c.Unlock(key, id)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("TryLock: %v", err)
+	}
+	if held := c.HeldLocks(); len(held) != 0 {
+		t.Fatalf("HeldLocks after every worker released = %+v; want none", held)
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 锁key各部分之间的分隔符
+const KeySeparator = ":"
+
+// Key
+// 由资源类型、ID、子范围组成的锁key，同一个逻辑上的锁在不同的代码路径中总是得到同一个字符串
+//
+//	k := client.KB("order", orderID).Sub("shipping")
+//	client.Lock(k.String(), uniqID, 5) // "order:123:shipping"
+//
+// 零值和构造出错的Key不能使用，String 返回空字符串，加锁时返回"lock key is nil"；构造出错的原因见 Err
+type Key struct {
+	parts []string
+	err   error
+}
+
+// KB
+// 创建资源resource上id的key，resource转换为小写；id可以是字符串、整数、无符号整数或fmt.Stringer
+func KB(resource string, id interface{}) Key {
+	k := Key{}.add(strings.ToLower(resource))
+	part, ok := formatKeyPart(id)
+	if !ok && k.err == nil {
+		k.err = fmt.Errorf("%w: unsupported id type %T", ErrInvalidKey, id)
+	}
+	return k.add(part)
+}
+
+// Sub
+// 在k后面追加一级子范围，返回新的Key，k本身不变
+func (k Key) Sub(scope string) Key {
+	return k.add(scope)
+}
+
+// String
+// 返回规范的key字符串，各部分用 KeySeparator 连接，出错时为空
+func (k Key) String() string {
+	if k.err != nil {
+		return ""
+	}
+	return strings.Join(k.parts, KeySeparator)
+}
+
+// Err
+// 返回构造时的错误，包装了 ErrInvalidKey
+func (k Key) Err() error {
+	if k.err == nil && len(k.parts) == 0 {
+		return fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	return k.err
+}
+
+// add
+// 追加一个部分，部分不能为空、不能包含分隔符和空白字符，否则不同的组合可能得到同一个字符串
+func (k Key) add(part string) Key {
+	if k.err != nil {
+		return k
+	}
+	switch {
+	case part == "":
+		k.err = fmt.Errorf("%w: empty component after %q", ErrInvalidKey, strings.Join(k.parts, KeySeparator))
+		return k
+	case strings.Contains(part, KeySeparator):
+		k.err = fmt.Errorf("%w: component %q contains %q", ErrInvalidKey, part, KeySeparator)
+		return k
+	case strings.IndexFunc(part, isKeySpace) >= 0:
+		k.err = fmt.Errorf("%w: component %q contains whitespace", ErrInvalidKey, part)
+		return k
+	}
+	// 复制一份，避免和其他从同一个Key派生的Key共用底层数组
+	parts := make([]string, len(k.parts), len(k.parts)+1)
+	copy(parts, k.parts)
+	k.parts = append(parts, part)
+	return k
+}
+
+// formatKeyPart
+// 把ID格式化为字符串，不支持的类型返回false
+func formatKeyPart(id interface{}) (string, bool) {
+	switch v := id.(type) {
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return "", false
+}
+
+// isKeySpace
+// key中不允许的空白字符
+func isKeySpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\v' || r == '\f'
+}
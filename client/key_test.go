@@ -0,0 +1,79 @@
+package client_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lzw5399/rwlock/client"
+)
+
+type orderID int
+
+func (id orderID) String() string { return "o-" + string(rune('0'+int(id))) }
+
+func TestKeyCanonical(t *testing.T) {
+	tests := []struct {
+		key  client.Key
+		want string
+	}{
+		{client.KB("order", 123), "order:123"},
+		{client.KB("Order", "123"), "order:123"},
+		{client.KB("ORDER", int64(123)), "order:123"},
+		{client.KB("order", uint64(123)), "order:123"},
+		{client.KB("order", int32(123)), "order:123"},
+		{client.KB("order", uint(123)), "order:123"},
+		{client.KB("order", uint32(123)), "order:123"},
+		{client.KB("order", -5), "order:-5"},
+		{client.KB("order", orderID(7)), "order:o-7"},
+		{client.KB("order", 123).Sub("shipping"), "order:123:shipping"},
+		{client.KB("order", 123).Sub("shipping").Sub("label"), "order:123:shipping:label"},
+	}
+	for _, tt := range tests {
+		if err := tt.key.Err(); err != nil {
+			t.Errorf("%q: unexpected error %v", tt.want, err)
+			continue
+		}
+		if got := tt.key.String(); got != tt.want {
+			t.Errorf("String() = %q; want %q", got, tt.want)
+		}
+	}
+}
+
+// 会让不同的组合得到同一个字符串的部分都要拒绝
+func TestKeyRejectsCollisions(t *testing.T) {
+	tests := []struct {
+		name string
+		key  client.Key
+	}{
+		{"zero value", client.Key{}},
+		{"separator in id", client.KB("order", "1:shipping")},
+		{"separator in resource", client.KB("order:1", "shipping")},
+		{"separator in sub", client.KB("order", 1).Sub("a:b")},
+		{"empty resource", client.KB("", 1)},
+		{"empty id", client.KB("order", "")},
+		{"empty sub", client.KB("order", 1).Sub("")},
+		{"space", client.KB("order", "1 2")},
+		{"tab in sub", client.KB("order", 1).Sub("a\tb")},
+		{"newline", client.KB("order", "1\n")},
+		{"unsupported id", client.KB("order", 1.5)},
+		{"error is kept by Sub", client.KB("order", "").Sub("shipping")},
+	}
+	for _, tt := range tests {
+		if err := tt.key.Err(); !errors.Is(err, client.ErrInvalidKey) {
+			t.Errorf("%s: Err() = %v; want ErrInvalidKey", tt.name, err)
+		}
+		if s := tt.key.String(); s != "" {
+			t.Errorf("%s: String() = %q; want empty", tt.name, s)
+		}
+	}
+}
+
+// Sub 返回新的Key，从同一个Key派生的Key互不影响
+func TestKeySubDoesNotAlias(t *testing.T) {
+	base := client.KB("order", 1).Sub("a")
+	x := base.Sub("x")
+	y := base.Sub("y")
+	if base.String() != "order:1:a" || x.String() != "order:1:a:x" || y.String() != "order:1:a:y" {
+		t.Fatalf("base %q, x %q, y %q", base, x, y)
+	}
+}
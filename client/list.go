@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// 锁在redis中的key前缀，需要和lock.lua保持一致
+const writeKeyPrefix = "_write_for_lock__"
+const readKeyPrefix = "_read_for_lock__"
+const readersKeyPrefix = "_readers_for_lock__"
+const sharedReadKeyPrefix = "_shared_read_for_lock__"
+const weightedKeyPrefix = "_weighted_for_lock__"
+
+// 每次SCAN的数量
+const scanCount = 100
+
+// 锁的类型
+const LockTypeWrite = "write"
+const LockTypeRead = "read"
+const LockTypeFree = "free"
+
+// LockSummary
+// ListLocks 返回的单个锁的概要
+type LockSummary struct {
+	LockStatus
+	// write: 写锁；read: 读锁；free: key还在但已经没有持有者
+	Type string
+}
+
+// String
+// 同 LockStatus.String，前面加上锁的类型，如 "[write] order:1: write-locked by abc123 (ttl 3.2s)"
+func (s LockSummary) String() string {
+	return "[" + s.Type + "] " + s.LockStatus.String()
+}
+
+// ListLocks
+// 列出所有匹配pattern的锁，pattern的语法和redis的SCAN MATCH一致
+// 使用SCAN而不是KEYS，不会阻塞redis；集群模式下会扫描所有master节点
+// 只用于运维工具，key的数量很多时耗时较长，可以通过ctx取消
+func ListLocks(ctx context.Context, pattern string) ([]LockSummary, error) {
+	return std.ListLocks(ctx, pattern)
+}
+
+// ListLocks
+// 同 ListLocks
+func (c *Client) ListLocks(ctx context.Context, pattern string) ([]LockSummary, error) {
+	if c.conn() == nil {
+		return nil, ErrNotInitialized
+	}
+	keys, err := c.scanLockKeys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]LockSummary, 0, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		st, err := c.Status(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		sum := LockSummary{LockStatus: st, Type: LockTypeFree}
+		if len(st.Owner) > 0 {
+			sum.Type = LockTypeWrite
+		} else if st.Readers > 0 {
+			sum.Type = LockTypeRead
+		}
+		list = append(list, sum)
+	}
+	return list, nil
+}
+
+// scanLockKeys
+// 用SCAN找出所有匹配pattern的锁，返回排好序的锁的key（不带前缀）；集群模式下扫描所有master节点
+func (c *Client) scanLockKeys(ctx context.Context, pattern string) ([]string, error) {
+	if len(pattern) <= 0 {
+		pattern = "*"
+	}
+
+	var mu sync.Mutex
+	seen := make(map[string]struct{})
+	scan := func(ctx context.Context, c redis.UniversalClient) error {
+		for _, prefix := range []string{writeKeyPrefix, readKeyPrefix, readersKeyPrefix, sharedReadKeyPrefix, weightedKeyPrefix} {
+			var cursor uint64
+			for {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				keys, next, err := c.Scan(ctx, cursor, prefix+pattern, scanCount).Result()
+				if err != nil {
+					return err
+				}
+				mu.Lock()
+				for _, k := range keys {
+					seen[strings.TrimPrefix(k, prefix)] = struct{}{}
+				}
+				mu.Unlock()
+				if cursor = next; cursor == 0 {
+					break
+				}
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if cluster, ok := c.conn().(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return scan(ctx, node)
+		})
+	} else {
+		err = scan(ctx, c.conn())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
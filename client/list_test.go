@@ -0,0 +1,31 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// 只有按权重的读者持有的锁也能列出来
+func TestListLocksWeightedOnly(t *testing.T) {
+	t.Parallel()
+	c, cleanup := rwlocktest.NewTestClient(t)
+	defer cleanup()
+	if err := c.RLockWeighted("pool:1", "r1", 10, 30); err != nil {
+		t.Fatal(err)
+	}
+	c.Lock("order:1", "a", 30)
+
+	list, err := c.ListLocks(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].Key != "pool:1" {
+		t.Fatalf("ListLocks = %+v; want order:1 and pool:1", list)
+	}
+	if list[1].Type != client.LockTypeRead {
+		t.Fatalf("pool:1 type = %v; want read", list[1].Type)
+	}
+}
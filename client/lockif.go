@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// LockIf
+// 条件加写锁，只尝试一次
+// expectFree为true时只有锁完全空闲（没有写锁和读者）才会加锁，
+// 为false时不检查状态，等价于只尝试一次的普通加锁
+// 状态检查和加锁在同一个Lua脚本中完成，不存在检查后被别人抢先的问题
+// 锁在 UnlockWithCooldown 的冷却期内时返回false和 ErrInCooldown
+func LockIf(key, uniqID string, expireTime int64, expectFree bool) (bool, error) {
+	return std.LockIf(key, uniqID, expireTime, expectFree)
+}
+
+// LockIf
+// 同 LockIf
+func (c *Client) LockIf(key, uniqID string, expireTime int64, expectFree bool) (bool, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return false, err
+	}
+	if !expectFree {
+		return c.TryLock(key, uniqID, expireTime, 0)
+	}
+	return c.sendOnce(context.Background(), key, uniqID, LockIfCmd, expireTime, "")
+}
+
+// LockIfOwner
+// 只有写锁当前由expectOwner持有时，才把锁交给uniqID并重置过期时间
+// 用于锁的交接：旧的持有者确认交接后，新的持有者原子地接管锁；冷却期的规则同 LockIf
+func LockIfOwner(key, uniqID string, expireTime int64, expectOwner string) (bool, error) {
+	return std.LockIfOwner(key, uniqID, expireTime, expectOwner)
+}
+
+// LockIfOwner
+// 同 LockIfOwner
+func (c *Client) LockIfOwner(key, uniqID string, expireTime int64, expectOwner string) (bool, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return false, err
+	}
+	if len(expectOwner) <= 0 {
+		return c.LockIf(key, uniqID, expireTime, true)
+	}
+	return c.sendOnce(context.Background(), key, uniqID, LockIfCmd, expireTime, expectOwner)
+}
+
+// Handoff
+// 把fromUniqID持有的写锁原子地转交给toUniqID，锁的剩余过期时间不变
+// 锁不是fromUniqID持有时返回false
+// 滚动发布时旧进程把锁交给新进程，中间没有释放的窗口，其他等待者抢不到锁
+func Handoff(key, fromUniqID, toUniqID string) (bool, error) {
+	return std.Handoff(key, fromUniqID, toUniqID)
+}
+
+// Handoff
+// 同 Handoff
+func (c *Client) Handoff(key, fromUniqID, toUniqID string) (bool, error) {
+	if len(toUniqID) <= 0 {
+		return false, errors.New("handoff target is nil")
+	}
+	return c.sendOnce(context.Background(), key, fromUniqID, HandoffCmd, 0, toUniqID)
+}
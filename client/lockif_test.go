@@ -0,0 +1,35 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// 冷却期内条件加锁和普通加锁一样失败，返回 ErrInCooldown，冷却期过后可以加锁
+func TestLockIfDuringCooldown(t *testing.T) {
+	t.Parallel()
+	c, m, _ := rwlocktest.NewTestServer(t)
+	c.Lock("order:1", "a", 30)
+	if err := c.UnlockWithCooldown("order:1", "a", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, expectFree := range []bool{true, false} {
+		ok, err := c.LockIf("order:1", "b", 5, expectFree)
+		if ok || !errors.Is(err, client.ErrInCooldown) {
+			t.Fatalf("LockIf(expectFree=%t) = %t, %v; want false, ErrInCooldown", expectFree, ok, err)
+		}
+	}
+	if st, err := c.Status(context.Background(), "order:1"); err != nil || st.Owner != "" {
+		t.Fatalf("Status = %+v, %v; want no owner", st, err)
+	}
+
+	m.FastForward(11 * time.Second)
+	if ok, err := c.LockIf("order:1", "b", 5, true); !ok || err != nil {
+		t.Fatalf("LockIf after cooldown = %t, %v; want true", ok, err)
+	}
+}
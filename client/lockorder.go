@@ -0,0 +1,100 @@
+//go:build rwlock_lockorder
+// +build rwlock_lockorder
+
+package client
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// 加锁顺序检查，只在使用 -tags rwlock_lockorder 编译时生效
+// 记录每个协程当前持有的写锁，以及观察到的"持有A时加B"的顺序，
+// 之后某个协程持有B时去加A，说明两个协程按相反的顺序加锁时可能互相等待，打印警告
+// 只是发现潜在的死锁，不会阻止加锁；在一个协程加锁、另一个协程释放的用法会留下误报
+
+type lockOrderState struct {
+	mu sync.Mutex
+	// 协程ID -> 按加锁顺序持有的key
+	held map[uint64][]string
+	// before[a][b] 表示观察到过持有a时加b
+	before map[string]map[string]struct{}
+}
+
+var lockOrder = &lockOrderState{
+	held:   make(map[uint64][]string),
+	before: make(map[string]map[string]struct{}),
+}
+
+// checkLockOrder
+// 加锁前检查顺序，在阻塞之前打印警告，死锁发生后也能看到
+// 日志的字段只在发现问题时才构建
+func checkLockOrder(logger Logger, key, lockCmd, uniqID string) {
+	gid := goroutineID()
+	lockOrder.mu.Lock()
+	defer lockOrder.mu.Unlock()
+	for _, h := range lockOrder.held[gid] {
+		if h == key {
+			continue
+		}
+		if _, ok := lockOrder.before[key][h]; ok {
+			logWith(logger, Fields{FieldKey: key, FieldOp: lockCmd, FieldToken: uniqID}).
+				Printf("lock order inversion: acquiring %s while holding %s, but %s was acquired before %s elsewhere", key, h, key, h)
+		}
+	}
+}
+
+// recordLockAcquired
+// 加锁成功后记录当前协程持有的锁和观察到的顺序
+func recordLockAcquired(key string) {
+	gid := goroutineID()
+	lockOrder.mu.Lock()
+	defer lockOrder.mu.Unlock()
+	for _, h := range lockOrder.held[gid] {
+		if h == key {
+			continue
+		}
+		set, ok := lockOrder.before[h]
+		if !ok {
+			set = make(map[string]struct{})
+			lockOrder.before[h] = set
+		}
+		set[key] = struct{}{}
+	}
+	lockOrder.held[gid] = append(lockOrder.held[gid], key)
+}
+
+// recordLockReleased
+// 释放锁后从当前协程持有的锁中删除
+func recordLockReleased(key string) {
+	gid := goroutineID()
+	lockOrder.mu.Lock()
+	defer lockOrder.mu.Unlock()
+	keys := lockOrder.held[gid]
+	for i := len(keys) - 1; i >= 0; i-- {
+		if keys[i] == key {
+			keys = append(keys[:i], keys[i+1:]...)
+			break
+		}
+	}
+	if len(keys) == 0 {
+		delete(lockOrder.held, gid)
+	} else {
+		lockOrder.held[gid] = keys
+	}
+}
+
+// goroutineID
+// 从调用栈的第一行"goroutine 123 [running]:"中解析协程ID，只用于调试
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
@@ -0,0 +1,12 @@
+//go:build !rwlock_lockorder
+// +build !rwlock_lockorder
+
+package client
+
+// 没有使用 -tags rwlock_lockorder 编译时，加锁顺序检查是空函数，没有任何开销
+
+func checkLockOrder(logger Logger, key, lockCmd, uniqID string) {}
+
+func recordLockAcquired(key string) {}
+
+func recordLockReleased(key string) {}
@@ -0,0 +1,50 @@
+//go:build rwlock_lockorder
+// +build rwlock_lockorder
+
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestLockOrderInversion(t *testing.T) {
+	logger := &recordLogger{}
+	// 先按a、b的顺序加锁
+	checkLockOrder(logger, "order:a", LockCmd, "t1")
+	recordLockAcquired("order:a")
+	checkLockOrder(logger, "order:b", LockCmd, "t1")
+	recordLockAcquired("order:b")
+	recordLockReleased("order:b")
+	recordLockReleased("order:a")
+	if len(logger.lines) != 0 {
+		t.Fatalf("warnings for a consistent order: %q", logger.lines)
+	}
+
+	// 另一个协程持有b时加a
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		checkLockOrder(logger, "order:b", LockCmd, "t2")
+		recordLockAcquired("order:b")
+		checkLockOrder(logger, "order:a", LockCmd, "t2")
+		recordLockReleased("order:b")
+	}()
+	<-done
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0], "lock order inversion: acquiring order:a while holding order:b") {
+		t.Fatalf("warnings = %q; want one inversion", logger.lines)
+	}
+}
@@ -0,0 +1,32 @@
+package client
+
+// Fields
+// 结构化日志的字段
+type Fields map[string]interface{}
+
+// 日志字段的名称
+const (
+	FieldKey       = "key"
+	FieldOp        = "op"
+	FieldToken     = "token"
+	FieldAttempt   = "attempt"
+	FieldAcquireID = "acquire_id"
+)
+
+// FieldLogger
+// 结构化日志接口，WithLogger 传入的 Logger 实现了它时，日志的key、操作、uniqID、尝试次数、加锁ID作为单独的字段输出
+// 消息本身不变，只实现 Logger 时和原来一样
+type FieldLogger interface {
+	Logger
+	// 返回带有fields的 Logger，不能修改自身
+	WithFields(fields Fields) Logger
+}
+
+// logWith
+// 带上fields的日志，logger不支持结构化字段时原样返回
+func logWith(l Logger, fields Fields) Logger {
+	if fl, ok := l.(FieldLogger); ok {
+		return fl.WithFields(fields)
+	}
+	return l
+}
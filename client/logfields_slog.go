@@ -0,0 +1,46 @@
+//go:build go1.21
+// +build go1.21
+
+package client
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// SlogLogger
+// log/slog 的适配器，实现了 FieldLogger，日志以Info级别输出
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger
+// 用l创建 SlogLogger，l为nil时使用slog.Default()
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{l: l}
+}
+
+// Printf
+// 同 Logger
+func (s *SlogLogger) Printf(format string, v ...interface{}) {
+	s.l.Info(fmt.Sprintf(format, v...))
+}
+
+// WithFields
+// 同 FieldLogger，字段按名称排序
+func (s *SlogLogger) WithFields(fields Fields) Logger {
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	args := make([]interface{}, 0, 2*len(names))
+	for _, k := range names {
+		args = append(args, k, fields[k])
+	}
+	return &SlogLogger{l: s.l.With(args...)}
+}
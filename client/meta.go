@@ -0,0 +1,27 @@
+package client
+
+import "context"
+
+// 锁的元数据的最大长度（字节）
+const MaxMetadataSize = 1024
+
+// LockWithMeta
+// 加写锁，并把metadata和锁一起保存到redis中，例如"job 12345 backup"
+// 通过 Status 可以看到当前持有者的元数据，方便排查卡住的锁；metadata的过期时间和锁一致，释放锁时删除
+func LockWithMeta(ctx context.Context, key, uniqID string, expireTime int64, metadata string) error {
+	return std.LockWithMeta(ctx, key, uniqID, expireTime, metadata)
+}
+
+// LockWithMeta
+// 同 LockWithMeta
+func (c *Client) LockWithMeta(ctx context.Context, key, uniqID string, expireTime int64, metadata string) error {
+	if len(metadata) > MaxMetadataSize {
+		return ErrMetadataTooLarge
+	}
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return err
+	}
+	_, err = c.acquireReply(ctx, key, uniqID, LockCmd, expireTime, AcquirePolicy{}, "", metadata)
+	return err
+}
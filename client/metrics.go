@@ -0,0 +1,26 @@
+package client
+
+import "time"
+
+// Metrics
+// 监控指标的接口，实现时可以嵌入 NopMetrics，只实现关心的方法
+type Metrics interface {
+	// 收到EOF后重新初始化客户端
+	IncReconnect()
+	// 收到NOSCRIPT后重新加载脚本
+	IncScriptReload()
+}
+
+// NopMetrics
+// 不做任何事的 Metrics，默认使用
+type NopMetrics struct{}
+
+func (NopMetrics) IncReconnect()    {}
+func (NopMetrics) IncScriptReload() {}
+
+// AcquireMetrics
+// 可选的加锁指标，Metrics 的实现同时实现了这个接口时，每次带重试的加锁结束后调用
+// acquireID和日志、事件中的一致，基数很高，不要直接作为指标的标签，适合放到trace或者exemplar中
+type AcquireMetrics interface {
+	ObserveAcquire(key string, acquireID uint64, attempts int, waited time.Duration, err error)
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// Migrate 对一个key最多调用脚本的次数，每次最多清理100条过期记录
+const maxMigrateRounds = 100
+
+// Migrate
+// 把key在redis中的数据结构升级到当前脚本的格式，升级在脚本中完成，和加锁释放是原子的，不需要清空已有的锁
+// 已经是当前格式时只清理过期的读者和离线的等待者，可以重复调用；遇到无法识别的格式时不做修改，返回包含key和类型的错误
+// 滚动升级时在新版本的进程中调用，旧版本的进程仍然可以正常使用升级后的key（目前的格式变化都是只增加新的key）
+func Migrate(ctx context.Context, key string) error {
+	return std.Migrate(ctx, key)
+}
+
+// Migrate
+// 同 Migrate
+func (c *Client) Migrate(ctx context.Context, key string) error {
+	for i := 0; ; i++ {
+		res, err := c.sendLock(withFullReply(ctx), key, "", MigrateCmd, 0)
+		if err != nil {
+			c.handleErrorContext(ctx, err)
+			return err
+		}
+		if res.IsError() {
+			return replyError(res)
+		}
+		// 过期记录很多时一次清理不完，继续调用；这次没有清理任何记录或者调用次数达到上限时停止，剩下的交给 GCReaders
+		if !res.More || res.Cleaned == 0 || i+1 >= maxMigrateRounds {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// MigrateAll
+// 对所有匹配pattern的锁调用 Migrate，pattern同 ListLocks，返回升级的锁的数量
+// 某个key失败时继续升级其他key，返回的错误中包含第一个失败的key
+func MigrateAll(ctx context.Context, pattern string) (int, error) {
+	return std.MigrateAll(ctx, pattern)
+}
+
+// MigrateAll
+// 同 MigrateAll
+func (c *Client) MigrateAll(ctx context.Context, pattern string) (int, error) {
+	if c.conn() == nil {
+		return 0, ErrNotInitialized
+	}
+	keys, err := c.scanLockKeys(ctx, pattern)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	var first error
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if err := c.Migrate(ctx, key); err != nil {
+			if first == nil {
+				first = fmt.Errorf("migrate %s: %w", key, err)
+			}
+			continue
+		}
+		n++
+	}
+	return n, first
+}
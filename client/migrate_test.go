@@ -0,0 +1,96 @@
+package client_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// 只有写锁、读锁计数和等待队列的旧格式，升级后锁照常可以使用，离线的等待者被清理
+func TestMigrateOldLayout(t *testing.T) {
+	t.Parallel()
+	c, m, _ := countingServer(t)
+	m.Set("_write_for_lock__job:1", "old-owner")
+	m.SetTTL("_write_for_lock__job:1", 30e9)
+	m.Push("_wait_queue__job:1", "crashed")
+	m.HSet("_wait_queue_hash_set__job:1", "crashed", "1")
+
+	if err := c.Migrate(context.Background(), "job:1"); err != nil {
+		t.Fatal(err)
+	}
+	st, err := c.Status(context.Background(), "job:1")
+	if err != nil || st.Owner != "old-owner" {
+		t.Fatalf("Status = %+v, %v; want owner old-owner", st, err)
+	}
+	if n, err := c.WaiterCount("job:1"); err != nil || n != 0 {
+		t.Fatalf("WaiterCount = %d, %v; want the offline waiter removed", n, err)
+	}
+	if ok, _ := c.TryLock("job:1", "new", 5, 0); ok {
+		t.Fatal("TryLock succeeded while the migrated lock is held")
+	}
+	c.Unlock("job:1", "old-owner")
+	if ok, err := c.TryLock("job:1", "new", 5, 0); !ok || err != nil {
+		t.Fatalf("TryLock after Unlock = %t, %v; want true", ok, err)
+	}
+}
+
+// 类型不对的key返回包含key和类型的错误，不修改任何数据
+func TestMigrateUnknownLayout(t *testing.T) {
+	t.Parallel()
+	c, m, _ := countingServer(t)
+	m.Set("_write_for_lock__job:1", "a")
+	m.Set("_readers_for_lock__job:1", "not a zset")
+
+	err := c.Migrate(context.Background(), "job:1")
+	if err == nil || !strings.Contains(err.Error(), "_readers_for_lock__job:1 is string, expect zset") {
+		t.Fatalf("err = %v; want an unknown layout error naming the key and types", err)
+	}
+	if v, _ := m.Get("_readers_for_lock__job:1"); v != "not a zset" {
+		t.Fatalf("readers key = %q; want it untouched", v)
+	}
+}
+
+// 过期的读者超过一次能清理的数量时继续调用，直到清理完
+func TestMigrateMultipleRounds(t *testing.T) {
+	t.Parallel()
+	c, m, calls := countingServer(t)
+	for i := 0; i < 250; i++ {
+		id := "r" + strconv.Itoa(i)
+		m.ZAdd("_readers_for_lock__job:1", 1, id)
+		m.HSet("_reader_counts_for_lock__job:1", id, "1")
+	}
+
+	before := atomic.LoadInt64(calls)
+	if err := c.Migrate(context.Background(), "job:1"); err != nil {
+		t.Fatal(err)
+	}
+	// 每次清理100个：100、100、50
+	if n := atomic.LoadInt64(calls) - before; n != 3 {
+		t.Fatalf("MIGRATE calls = %d; want 3", n)
+	}
+	if m.Exists("_readers_for_lock__job:1") {
+		members, _ := m.ZMembers("_readers_for_lock__job:1")
+		t.Fatalf("%d expired readers left", len(members))
+	}
+}
+
+// 某个key升级失败时继续升级其他key，返回成功的数量和第一个失败的key
+func TestMigrateAllPartialFailure(t *testing.T) {
+	t.Parallel()
+	c, m, _ := countingServer(t)
+	c.Lock("job:1", "a", 30)
+	c.Lock("job:2", "b", 30)
+	c.Lock("job:3", "c", 30)
+	m.Del("_meta_for_lock__job:2")
+	m.HSet("_meta_for_lock__job:2", "f", "v")
+
+	n, err := c.MigrateAll(context.Background(), "job:*")
+	if n != 2 {
+		t.Fatalf("MigrateAll = %d; want 2", n)
+	}
+	if err == nil || !strings.Contains(err.Error(), "migrate job:2") {
+		t.Fatalf("err = %v; want it to name job:2", err)
+	}
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+)
+
+// LockMinTTL
+// 加写锁，并保证拿到的锁至少还有minTTL才过期，过期时间expireTime向上取整到秒
+// 能拿到的锁短于minTTL时返回 ErrLeaseTooShort，不会给出一把马上就过期的锁；检查在脚本中完成，和加锁是原子的
+// 注意minTTL从redis加锁的时刻算起，不包括回馈返回客户端的网络耗时
+func LockMinTTL(key, uniqID string, minTTL, expireTime time.Duration) error {
+	return std.LockMinTTL(key, uniqID, minTTL, expireTime)
+}
+
+// LockMinTTL
+// 同 LockMinTTL
+func (c *Client) LockMinTTL(key, uniqID string, minTTL, expireTime time.Duration) error {
+	secs := int64(math.Ceil(expireTime.Seconds()))
+	if secs <= 0 || secs > MaxExpire {
+		return ErrInvalidExpire
+	}
+	// ARGV[3]~ARGV[7]：排队位置标记、元数据、值的key、分配fencing token、最短剩余时间（毫秒）
+	_, err := c.acquireReply(context.Background(), key, uniqID, LockCmd, secs, AcquirePolicy{}, "", "", "", "", strconv.FormatInt(minTTL.Milliseconds(), 10))
+	return err
+}
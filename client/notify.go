@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// 锁释放通知的channel前缀，需要和lock.lua保持一致
+const notifyChannelPrefix = "rwlock:notify:"
+
+// notifier
+// 每个客户端只有一个PSUBSCRIBE连接，按锁的key把通知分发给本进程内的等待者
+type notifier struct {
+	mu      sync.Mutex
+	waiters map[string]map[chan struct{}]struct{}
+	pubsub  *redis.PubSub
+}
+
+// newNotifier
+// 创建还没有订阅的通知分发器
+func newNotifier() *notifier {
+	return &notifier{waiters: make(map[string]map[chan struct{}]struct{})}
+}
+
+// start
+// 在新的客户端上订阅通知，旧的订阅会被关闭
+// 重连（EOF重新初始化）后重新调用，避免等待者错过唤醒
+func (n *notifier) start(c redis.UniversalClient) error {
+	ps := c.PSubscribe(context.Background(), notifyChannelPrefix+"*")
+	// 等待订阅确认，订阅失败时直接返回错误
+	if _, err := ps.Receive(context.Background()); err != nil {
+		_ = ps.Close()
+		return err
+	}
+	n.mu.Lock()
+	old := n.pubsub
+	n.pubsub = ps
+	n.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	go n.run(ps)
+	// 重连期间可能错过了通知，唤醒所有等待者重新检查一次
+	n.wakeAll()
+	return nil
+}
+
+// stop
+// 关闭订阅
+func (n *notifier) stop() {
+	n.mu.Lock()
+	old := n.pubsub
+	n.pubsub = nil
+	n.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+}
+
+// run
+// 分发订阅到的通知，订阅关闭后退出
+func (n *notifier) run(ps *redis.PubSub) {
+	for msg := range ps.Channel() {
+		// 加锁的通知只给 WatchPrefix 使用，不能唤醒等待者
+		if msg.Payload == NotifyAcquire {
+			continue
+		}
+		n.wake(strings.TrimPrefix(msg.Channel, notifyChannelPrefix))
+	}
+}
+
+// register
+// 登记一个等待key的通知的等待者，返回的cancel必须调用
+func (n *notifier) register(key string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	n.mu.Lock()
+	set, ok := n.waiters[key]
+	if !ok {
+		set = make(map[chan struct{}]struct{})
+		n.waiters[key] = set
+	}
+	set[ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if set, ok := n.waiters[key]; ok {
+			delete(set, ch)
+			if len(set) == 0 {
+				delete(n.waiters, key)
+			}
+		}
+	}
+}
+
+// wake
+// 唤醒key的所有等待者，等待者还没处理上一次唤醒时不会阻塞
+func (n *notifier) wake(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.waiters[key] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// wakeAll
+// 唤醒所有等待者
+func (n *notifier) wakeAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, set := range n.waiters {
+		for ch := range set {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,600 @@
+package client
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/lzw5399/rwlock/tool"
+)
+
+// 默认的读锁过期时间（秒）
+const DefaultReadExpire int64 = 10
+
+// 默认的写锁过期时间（秒），expireTime传入非法值时使用
+const DefaultLockExpire int64 = 5
+
+// 默认的两次自动重连之间的最小间隔
+const DefaultReconnectInterval = time.Second
+
+// 默认的重试睡眠范围
+const DefaultBackoffMin = 10 * time.Millisecond
+const DefaultBackoffMax = 20 * time.Millisecond
+
+// 过期时间的上限（秒），一年
+// 更大的值基本都是传错了单位，而且脚本中换算成毫秒时会丢失精度
+const MaxExpire int64 = 365 * 24 * 3600
+
+// NoExpire
+// 写锁永不过期，必须显式传入
+// 持锁进程崩溃后锁不会自动释放，只适合人工介入的运维场景
+const NoExpire int64 = -1
+
+// Logger
+// 日志接口，默认输出到标准库log
+// 需要结构化字段时实现 FieldLogger，Go 1.21及以上可以使用 NewSlogLogger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// config
+// 客户端的可选配置
+type config struct {
+	// 读锁的默认过期时间（秒）
+	readExpire int64
+	// 日志
+	logger Logger
+	// Diagnose 使用的探测key
+	canaryKey string
+	// 分片数量和分片函数
+	shardCount int
+	sharder    Sharder
+	// 一次加锁允许重试的总时长，0表示不限制
+	maxAcquireDuration time.Duration
+	// 收到EOF时是否自动重新初始化客户端
+	autoReinit bool
+	// 初始化时对redis淘汰策略的检查方式
+	evictionCheck EvictionCheck
+	// 重试前随机睡眠的范围[backoffMin, backoffMax)
+	backoffMin time.Duration
+	backoffMax time.Duration
+	// 随机睡眠使用的随机数
+	rand *tool.LockedRand
+	// 是否订阅锁释放的通知
+	notify bool
+	// 续期发现锁丢失时的回调
+	onRenewFailed func(key, uniqID string, err error)
+	onRenewed     func(key, uniqID string, newTTL time.Duration)
+	// 监控指标
+	metrics Metrics
+	// 两次自动重连之间的最小间隔
+	reconnectInterval time.Duration
+	// 第一次尝试加锁前随机等待的最长时间
+	initialJitter time.Duration
+	// Guard 的ctx取消时自动释放锁
+	releaseOnCancel bool
+	// 所有读者共用一个过期时间
+	sharedReadTTL bool
+	// 开启通知时兜底轮询的间隔，为0时和不开启通知一样使用退避间隔
+	notifyFallback time.Duration
+	// 读者等待写锁超过这个时间后，新的写者让路
+	readerFairness time.Duration
+	// 脚本成功和占用时只返回整数
+	compactReply bool
+	// 按权重读锁的总容量
+	readCapacity int64
+	// 加锁等待超过slowAcquire时调用onSlowAcquire
+	slowAcquire   time.Duration
+	onSlowAcquire func(key string, elapsed time.Duration)
+	// 写锁加锁和释放的事件回调
+	onEvent func(Event)
+	// 加锁前检查是否和本进程持有的锁冲突
+	selfDeadlockCheck bool
+	// 每个key同时进行的加锁尝试的上限，0表示不限制
+	maxInflight int
+	// 持有的锁的上限，0表示不限制
+	maxHeld int
+	// 有写者等待时最多放行的读者数量，0表示不限制
+	readBatch int
+	// 覆盖redis配置中的连接池大小
+	pool poolConfig
+	// 续期出错时的重试
+	renewTolerance renewTolerance
+	// 按key限制加锁尝试的频率，nil表示不限制
+	limiter *keyLimiter
+	// 释放已经过期的写锁时的处理方式
+	unlockExpired UnlockExpiredPolicy
+	// 加锁时输出runtime/trace的task和region
+	runtimeTrace bool
+	// 加写锁成功时发布通知
+	publishAcquire bool
+	// 代替redis执行脚本，nil表示使用redis客户端
+	evalSha EvalShaFunc
+	// 审计stream的最大长度，0表示不记录
+	auditMaxLen int64
+}
+
+// 释放已经过期的写锁时的处理方式
+type UnlockExpiredPolicy int
+
+const (
+	// 忽略（默认）
+	UnlockExpiredIgnore UnlockExpiredPolicy = iota
+	// UnlockE 和 Handle.Release 返回 ErrLockExpired
+	UnlockExpiredError
+)
+
+// renewTolerance
+// 续期出错时在window内再重试retries次
+type renewTolerance struct {
+	retries int
+	window  time.Duration
+}
+
+// poolConfig
+// 连接池的设置，0表示使用redis配置中的值
+type poolConfig struct {
+	size    int
+	minIdle int
+}
+
+// apply
+// 把设置过的值写到redis的配置中
+func (p poolConfig) apply(size, minIdle *int) {
+	if p.size > 0 {
+		*size = p.size
+	}
+	if p.minIdle > 0 {
+		*minIdle = p.minIdle
+	}
+}
+
+func defaultConfig() *config {
+	return &config{
+		readExpire:        DefaultReadExpire,
+		logger:            log.New(os.Stderr, "[rwlock] ", log.LstdFlags),
+		canaryKey:         DefaultCanaryKey,
+		shardCount:        1,
+		sharder:           CRC32Sharder,
+		autoReinit:        true,
+		backoffMin:        DefaultBackoffMin,
+		backoffMax:        DefaultBackoffMax,
+		rand:              tool.NewLockedRand(nil),
+		metrics:           NopMetrics{},
+		readCapacity:      DefaultReadCapacity,
+		reconnectInterval: DefaultReconnectInterval,
+	}
+}
+
+// Option
+// 初始化时传入的可选配置
+type Option func(*config)
+
+// WithReadExpire
+// 设置读锁的默认过期时间（秒），小于等于0或超过 MaxExpire 时忽略
+func WithReadExpire(sec int64) Option {
+	return func(c *config) {
+		if sec > 0 && sec <= MaxExpire {
+			c.readExpire = sec
+		}
+	}
+}
+
+// WithLogger
+// 设置日志，传入nil时忽略
+func WithLogger(l Logger) Option {
+	return func(c *config) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// WithCanaryKey
+// 设置 Diagnose 使用的探测key
+func WithCanaryKey(key string) Option {
+	return func(c *config) {
+		if len(key) > 0 {
+			c.canaryKey = key
+		}
+	}
+}
+
+// WithShardCount
+// 设置分片数量，用于 ShardOf 计算key所在的分片
+func WithShardCount(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.shardCount = n
+		}
+	}
+}
+
+// WithSharder
+// 设置自定义的分片函数，传入nil时使用默认的 CRC32Sharder
+func WithSharder(fn Sharder) Option {
+	return func(c *config) {
+		if fn != nil {
+			c.sharder = fn
+		}
+	}
+}
+
+// WithMaxAcquireDuration
+// 限制一次加锁（Lock/RLock及其ctx版本）重试的总时长，超过后返回 ErrAcquireTimeout
+// 与单次请求的超时无关，0表示不限制
+func WithMaxAcquireDuration(d time.Duration) Option {
+	return func(c *config) {
+		if d >= 0 {
+			c.maxAcquireDuration = d
+		}
+	}
+}
+
+// WithAutoReinit
+// 收到EOF时是否自动重新初始化redis客户端，默认开启
+// 开启时对调用方透明，但会掩盖真实的连接问题，redis反复抖动时还会频繁重建客户端；
+// 关闭后EOF会作为错误返回（Lock等无返回值的方法会panic），由调用方自行决定何时调用 Reconnect
+func WithAutoReinit(enable bool) Option {
+	return func(c *config) {
+		c.autoReinit = enable
+	}
+}
+
+// WithEvictionCheck
+// 设置初始化时对redis maxmemory-policy的检查方式，默认 EvictionWarn
+// 非noeviction的策略在内存不足时可能淘汰锁的key，导致互斥失效
+func WithEvictionCheck(check EvictionCheck) Option {
+	return func(c *config) {
+		c.evictionCheck = check
+	}
+}
+
+// WithBackoffBounds
+// 设置重试前随机睡眠的范围[min, max)，min小于等于0或max小于min时忽略
+// min等于max时固定睡眠min
+func WithBackoffBounds(min, max time.Duration) Option {
+	return func(c *config) {
+		if min > 0 && max >= min {
+			c.backoffMin = min
+			c.backoffMax = max
+		}
+	}
+}
+
+// WithRandSource
+// 设置随机睡眠使用的随机源，压测时传入固定种子可以复现重试的节奏
+func WithRandSource(src rand.Source) Option {
+	return func(c *config) {
+		if src != nil {
+			c.rand = tool.NewLockedRand(src)
+		}
+	}
+}
+
+// WithRandSeed
+// 使用固定种子的随机源，等价于 WithRandSource(rand.NewSource(seed))
+func WithRandSeed(seed int64) Option {
+	return WithRandSource(rand.NewSource(seed))
+}
+
+// WithNotify
+// 开启锁释放通知，默认关闭
+// 开启后客户端会建立一个PSUBSCRIBE连接，锁释放时立即唤醒本进程内等待该锁的调用方，
+// 不用等到下一次轮询；轮询仍然保留，用于兜底错过的通知和锁自然过期的情况，间隔见 WithNotifyFallbackInterval
+// 写者等待读者排空时同样会被唤醒：RUnlock 等释放读锁的调用让读者数量归零时也会发出通知；
+// 最后的读者是过期（没有释放）而排空的不会发出通知，写者要等到下一次兜底轮询才会重试，
+// 兜底间隔受 MaxNotifyFallback 限制，不会因此让等待的写者失去排队的位置
+func WithNotify(enable bool) Option {
+	return func(c *config) {
+		c.notify = enable
+	}
+}
+
+// WithOnRenewFailed
+// 设置续期发现锁丢失时的回调，err为 ErrLockLost
+// 回调在续期协程中执行，不要在回调中阻塞
+func WithOnRenewFailed(fn func(key, uniqID string, err error)) Option {
+	return func(c *config) {
+		c.onRenewFailed = fn
+	}
+}
+
+// WithOnRenewed
+// 设置后台续期成功时的回调，newTTL为续期后锁的过期时间
+// 回调在续期协程中执行，执行时不持有任何内部的锁，但阻塞会推迟下一次续期
+func WithOnRenewed(fn func(key, uniqID string, newTTL time.Duration)) Option {
+	return func(c *config) {
+		c.onRenewed = fn
+	}
+}
+
+// WithMetrics
+// 设置监控指标，传入nil时忽略
+// 重连和脚本重新加载的次数突增通常说明redis不稳定，适合配置告警
+func WithMetrics(m Metrics) Option {
+	return func(c *config) {
+		if m != nil {
+			c.metrics = m
+		}
+	}
+}
+
+// WithSlowAcquire
+// 加锁等待超过threshold时调用fn，每次加锁最多调用一次，调用后继续等待
+// 用于在日志或链路追踪中发现竞争激烈的锁，fn在加锁的协程中执行，不要阻塞
+func WithSlowAcquire(threshold time.Duration, fn func(key string, elapsed time.Duration)) Option {
+	return func(c *config) {
+		if threshold > 0 && fn != nil {
+			c.slowAcquire = threshold
+			c.onSlowAcquire = fn
+		}
+	}
+}
+
+// WithReadCapacity
+// 设置按权重读锁（RLockWeighted）每个key的总容量，默认 DefaultReadCapacity
+func WithReadCapacity(n int64) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.readCapacity = n
+		}
+	}
+}
+
+// WithReconnectInterval
+// 设置两次自动重连之间的最小间隔，默认 DefaultReconnectInterval
+// 连续重连失败时间隔会翻倍，最多30秒
+func WithReconnectInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.reconnectInterval = d
+		}
+	}
+}
+
+// WithInitialJitter
+// 第一次尝试加锁前随机等待[0, max)，之后的重试不受影响
+// 整个集群同时重启去抢同一把锁时，可以把大家的重试节奏错开
+func WithInitialJitter(max time.Duration) Option {
+	return func(c *config) {
+		if max > 0 {
+			c.initialJitter = max
+		}
+	}
+}
+
+// WithReleaseOnCancel
+// 为true时，Guard 加锁用的ctx取消后在后台自动 Release，适合和请求同生命周期的锁
+// 和显式的 Release 同时发生时只会释放一次
+func WithReleaseOnCancel(release bool) Option {
+	return func(c *config) {
+		c.releaseOnCancel = release
+	}
+}
+
+// WithSharedReadTTL
+// 为true时读锁不再逐个登记读者，所有读者共用一个计数和过期时间，
+// 每次 RLock / RRefresh 都会把过期时间刷新为读锁的过期时间，过期前写锁无法获得
+// 每次加读锁只写一个key，适合读者很多、不需要区分读者的场景；代价是读者崩溃时要等共享的过期时间到了才会释放，
+// 并且 Status 不会列出读者，Upgrade 也无法使用
+func WithSharedReadTTL(shared bool) Option {
+	return func(c *config) {
+		c.sharedReadTTL = shared
+	}
+}
+
+// 兜底轮询间隔的上限
+// 排队的写者每次重试时刷新1秒的在线心跳，间隔超过1秒时心跳会在两次重试之间过期，被其他写者当作已经离开清出队列
+const MaxNotifyFallback = 500 * time.Millisecond
+
+// WithNotifyFallbackInterval
+// 设置开启 WithNotify 后，等待者没有收到通知时重试的间隔，默认和不开启通知时一样使用 WithBackoffBounds 的间隔
+// 兜底轮询只用来发现错过的通知，以及不会发通知的锁自然过期，可以比退避间隔长一些以减少redis的压力；
+// 但间隔越长，错过通知或者锁过期时等待者拿到锁的延迟也越大，最多会多等一个间隔
+// 超过 MaxNotifyFallback 时使用 MaxNotifyFallback，否则等待中的写者会因为心跳过期失去排队的位置
+func WithNotifyFallbackInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > MaxNotifyFallback {
+			d = MaxNotifyFallback
+		}
+		if d > 0 {
+			c.notifyFallback = d
+		}
+	}
+}
+
+// WithReaderFairness
+// 设置读者的公平窗口，默认不限制
+// 读者因为写锁等待超过window后，新的写者不再加锁，等这些读者拿到读锁；
+// 避免写者一个接一个加锁时读者一直拿不到锁。保证的是：读者最多等待window加上当前写者持有锁的时间
+// 只对 RLock、RLockN、RLockUntil、RLockWithStop、RLockGet 生效，读者超过3秒没有重试视为已经放弃，不再让路
+// 反过来写者要等所有读者释放，读者源源不断时写者仍然可能等待很久
+func WithReaderFairness(window time.Duration) Option {
+	return func(c *config) {
+		if window > 0 {
+			c.readerFairness = window
+		}
+	}
+}
+
+// WithCompactReply
+// 为true时，加锁、释放、续期等只关心结果的指令，脚本在成功和占用时只返回1和0，不再编码JSON，默认关闭
+// 脚本出错时仍然返回带错误信息的JSON；需要读者数量、排队位置等字段的调用（RLockN、OnQueuePosition等）不受影响
+// 适合吞吐量很高的场景，代价是回馈中不再有debug等排查信息
+func WithCompactReply(compact bool) Option {
+	return func(c *config) {
+		c.compactReply = compact
+	}
+}
+
+// WithEventHandler
+// 设置写锁的事件回调，成功加写锁时收到 EventAcquired，真正释放时收到 EventReleased，事件中带有加锁时的元数据
+// 回调在加锁和释放的协程中同步执行，不要在回调中阻塞；需要写入消息队列等审计系统时请在回调中异步投递
+// 开启后 Lock 和 Unlock 不再使用 WithCompactReply 的整数回馈，因为需要从脚本读取元数据
+func WithEventHandler(fn func(Event)) Option {
+	return func(c *config) {
+		c.onEvent = fn
+	}
+}
+
+// WithSelfDeadlockCheck
+// 为true时，阻塞加锁前检查本进程是否已经用同一个uniqID持有这把锁：持有写锁再加读锁或写锁、
+// 持有读锁再加写锁会立即返回 ErrSelfDeadlock（无返回值的 Lock/RLock 会panic），而不是一直等待自己，默认关闭
+// 只依据本进程的记录，锁在redis中过期后本地不会感知；需要读锁变写锁请使用 Upgrade
+func WithSelfDeadlockCheck(enable bool) Option {
+	return func(c *config) {
+		c.selfDeadlockCheck = enable
+	}
+}
+
+// WithMaxInflightAcquires
+// 限制本进程对同一个key同时进行的加锁尝试数，超出的协程在本地按到达顺序排队，拿到名额后才开始访问redis
+// 名额在加锁成功或放弃时归还，持有锁期间不占用；本地排队的时间同样计入 WithMaxAcquireDuration 和ctx的deadline
+// 大量协程争抢同一个热点key时可以明显减少重试请求，默认不限制，n小于等于0时忽略
+func WithMaxInflightAcquires(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxInflight = n
+		}
+	}
+}
+
+// WithMaxHeldLocks
+// 本客户端持有的锁超过n把时，新的加锁立即返回 ErrTooManyLocks（无返回值的 Lock/RLock 会panic），默认不限制
+// 用于防止有bug的代码路径不停加锁却不释放；重入已经持有的锁不受限制
+// 检查和加锁不是原子的，并发加锁时可能短暂超过n把；持有的锁以 HeldLocks 的本地记录为准
+func WithMaxHeldLocks(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.maxHeld = n
+		}
+	}
+}
+
+// WithReadBatch
+// 读者准入控制，介于读优先和写优先之间，默认不限制（读优先：只要没有写锁，读者总能进来）
+// 设置后，写者开始排队等待时，之后到达的读者还能继续加入当前这一批，最多n个；超过后新的读者被拒绝并重试，
+// 已经持有读锁的读者释放后写者加锁，写者加锁成功后重新计数。n越小写者的等待时间越短，n越大读的吞吐越高
+// 只对 RLock、RLockN、RLockUntil、RLockWithStop、RLockGet 等加读锁的方法生效，重入的读锁也计入数量
+// 不同n下写者的耗时和读的吞吐见 BenchmarkReadBatch
+func WithReadBatch(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.readBatch = n
+		}
+	}
+}
+
+// WithPoolSize
+// 覆盖redis配置中的PoolSize（集群模式下是每个节点的连接池），n小于等于0时忽略
+// 默认使用redis配置中的值，go-redis未设置时为每个CPU 10个连接；BenchmarkLockUnlock 显示连接数超过并发加锁的协程数后没有收益，见README
+// 加锁和释放每次只在执行脚本的时候占用一个连接，等待中的协程每次重试也会占用一次；
+// 大量协程同时争抢锁时连接池耗尽会表现为等待连接的超时（PoolTimeout），这时调大PoolSize或者用 WithMaxInflightAcquires 限制重试
+func WithPoolSize(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.pool.size = n
+		}
+	}
+}
+
+// WithMinIdleConns
+// 覆盖redis配置中的MinIdleConns，预先建立连接，避免流量突增时加锁先等待建连，n小于等于0时忽略
+func WithMinIdleConns(n int) Option {
+	return func(c *config) {
+		if n > 0 {
+			c.pool.minIdle = n
+		}
+	}
+}
+
+// WithRenewTolerance
+// 后台续期遇到网络错误等临时问题时，在window内均匀地再重试retries次，而不是等到下一个续期周期，默认不重试
+// 重试都失败时只打印日志，等下一个续期周期；只有脚本明确回馈锁已经不属于自己时，才会调用 WithOnRenewFailed 设置的回调
+// window应该明显短于续期间隔（过期时间的1/3），retries或window小于等于0时忽略
+func WithRenewTolerance(retries int, window time.Duration) Option {
+	return func(c *config) {
+		if retries > 0 && window > 0 {
+			c.renewTolerance = renewTolerance{retries: retries, window: window}
+		}
+	}
+}
+
+// WithPerKeyRateLimit
+// 限制本客户端对同一个key发起加锁尝试的频率：每秒rate次，最多攒burst次，不管有多少协程在等这个key
+// 和 WithMaxInflightAcquires 限制同时等待的协程数不同，这里限制的是访问redis的QPS，用于保护共用的redis不被热点key打满
+// 被限流的协程同样响应ctx取消和 WithMaxAcquireDuration；rate小于等于0时忽略，burst小于1时按1处理
+func WithPerKeyRateLimit(rate float64, burst int) Option {
+	return func(c *config) {
+		if rate <= 0 {
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		c.limiter = newKeyLimiter(rate, burst)
+	}
+}
+
+// WithUnlockExpiredPolicy
+// 设置释放写锁时锁已经过期或者被别人持有的处理方式，默认 UnlockExpiredIgnore
+// UnlockExpiredError 时 UnlockE 和 Handle.Release 返回 ErrLockExpired，用于发现持有锁的时间超过了过期时间；
+// 无返回值的 Unlock 不受影响
+func WithUnlockExpiredPolicy(p UnlockExpiredPolicy) Option {
+	return func(c *config) {
+		c.unlockExpired = p
+	}
+}
+
+// WithRuntimeTrace
+// 开启后每次加锁都是一个runtime/trace的task（rwlock.acquire），等锁的时间是其中的region（rwlock.wait），
+// 并记录key、指令和失败时的错误，用于在`go tool trace`中查看协程花在等分布式锁上的时间；默认关闭
+// 只在进程正在采集trace时才有开销，和跨服务的分布式追踪互补
+func WithRuntimeTrace(enable bool) Option {
+	return func(c *config) {
+		c.runtimeTrace = enable
+	}
+}
+
+// WithAcquireNotify
+// 开启后加写锁成功时也在通知channel上发布 NotifyAcquire，WatchPrefix 才能收到加锁的事件
+// 每次加锁多一次PUBLISH，默认关闭；不影响 WithNotify 的等待者
+func WithAcquireNotify(enable bool) Option {
+	return func(c *config) {
+		c.publishAcquire = enable
+	}
+}
+
+// EvalShaFunc
+// 执行锁脚本的函数，参数同redis的EVALSHA；返回值为脚本的回馈（JSON字符串或整数回馈的int64）
+type EvalShaFunc func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error)
+
+// WithEvalSha
+// 所有锁指令通过fn执行，代替直接调用redis的EvalSha，用于包装重试、埋点，或者在测试中不依赖redis
+// fn返回的错误和redis的错误一样处理（如"NOSCRIPT ..."会触发重新加载脚本）；初始化、通知、Diagnose 等仍然使用redis客户端
+// 完全不连接redis时使用 NewClientWithEvalSha
+func WithEvalSha(fn EvalShaFunc) Option {
+	return func(c *config) {
+		c.evalSha = fn
+	}
+}
+
+// AuditStreamPrefix
+// 审计stream的key前缀，每个锁一个stream：rwlock:audit:<key>，需要和lock.lua保持一致
+const AuditStreamPrefix = "rwlock:audit:"
+
+// WithAuditStream
+// 开启后每次成功改变锁状态的指令（加锁、释放、升级、交接、驱逐读者等）都在同一个脚本中XADD到 AuditStreamPrefix+key，
+// 字段为op（指令）、id（uniqID）、expire（秒）、meta（写锁的元数据），消息ID中带有redis的时间，进程重启后仍然可以查询
+// stream用MAXLEN ~ maxLen限制长度（近似裁剪）；每次加锁释放多一次写入，默认关闭，maxLen小于等于0时忽略
+// 需要redis 5.0及以上
+func WithAuditStream(maxLen int64) Option {
+	return func(c *config) {
+		if maxLen > 0 {
+			c.auditMaxLen = maxLen
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 编码后的持有者信息的前缀，用于和普通的uniqID区分
+const ownerInfoPrefix = "rwo1"
+
+// OwnerInfo
+// 结构化的持有者信息，编码后作为uniqID使用，排查卡住的锁时可以从 Status 的Owner中解析出来
+type OwnerInfo struct {
+	Host string
+	PID  int
+	// 调用方自定义的标记，例如任务名、协程的用途
+	Tag string
+	// 创建的时间，编码时保留到毫秒
+	Time time.Time
+	// 随机生成的唯一标识，保证同一个进程里的多个持有者互不相同
+	Nonce string
+}
+
+// NewOwnerInfo
+// 使用当前的主机名、进程ID和时间创建持有者信息
+func NewOwnerInfo(tag string) OwnerInfo {
+	host, _ := os.Hostname()
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return OwnerInfo{
+		Host:  host,
+		PID:   os.Getpid(),
+		Tag:   tag,
+		Time:  time.Now(),
+		Nonce: hex.EncodeToString(b),
+	}
+}
+
+// Encode
+// 编码成uniqID，格式为 rwo1|host|pid|tag|毫秒时间戳|nonce，字段中的特殊字符会被转义
+func (o OwnerInfo) Encode() string {
+	fields := []string{
+		ownerInfoPrefix,
+		url.QueryEscape(o.Host),
+		strconv.Itoa(o.PID),
+		url.QueryEscape(o.Tag),
+		strconv.FormatInt(o.Time.UnixNano()/int64(time.Millisecond), 10),
+		url.QueryEscape(o.Nonce),
+	}
+	return strings.Join(fields, "|")
+}
+
+// Decode
+// 从 Encode 的结果中解析，不是 Encode 生成的uniqID返回 ErrInvalidOwner
+func (o *OwnerInfo) Decode(s string) error {
+	fields := strings.Split(s, "|")
+	if len(fields) != 6 || fields[0] != ownerInfoPrefix {
+		return ErrInvalidOwner
+	}
+	var err error
+	var d OwnerInfo
+	if d.Host, err = url.QueryUnescape(fields[1]); err != nil {
+		return fmt.Errorf("%w: host: %v", ErrInvalidOwner, err)
+	}
+	if d.PID, err = strconv.Atoi(fields[2]); err != nil {
+		return fmt.Errorf("%w: pid: %v", ErrInvalidOwner, err)
+	}
+	if d.Tag, err = url.QueryUnescape(fields[3]); err != nil {
+		return fmt.Errorf("%w: tag: %v", ErrInvalidOwner, err)
+	}
+	ms, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: time: %v", ErrInvalidOwner, err)
+	}
+	d.Time = time.Unix(0, ms*int64(time.Millisecond))
+	if d.Nonce, err = url.QueryUnescape(fields[5]); err != nil {
+		return fmt.Errorf("%w: nonce: %v", ErrInvalidOwner, err)
+	}
+	*o = d
+	return nil
+}
+
+// String
+// 格式如 `host-1 pid 1234 "backup" since 2021-01-02T15:04:05Z`
+func (o OwnerInfo) String() string {
+	return fmt.Sprintf("%s pid %d %q since %s", o.Host, o.PID, o.Tag, o.Time.Format(time.RFC3339))
+}
+
+// OwnerInfo
+// 把写锁持有者解析成 OwnerInfo，没有写锁或者持有者不是 Encode 生成的uniqID时返回false
+func (s LockStatus) OwnerInfo() (OwnerInfo, bool) {
+	var o OwnerInfo
+	if len(s.Owner) <= 0 || o.Decode(s.Owner) != nil {
+		return OwnerInfo{}, false
+	}
+	return o, true
+}
+
+// AcquireAs
+// 同 Acquire，使用owner编码后的uniqID，返回的 Handle 的Token即编码结果
+func AcquireAs(ctx context.Context, key string, owner OwnerInfo, expireTime int64) (*Handle, error) {
+	return std.AcquireAs(ctx, key, owner, expireTime)
+}
+
+// AcquireAs
+// 同 AcquireAs
+func (c *Client) AcquireAs(ctx context.Context, key string, owner OwnerInfo, expireTime int64) (*Handle, error) {
+	return c.Acquire(ctx, key, owner.Encode(), expireTime)
+}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// 按权重的读锁，见 RLockWeighted
+const LockTypeWeighted = "weighted"
+
+// Backoff
+// 第attempt次尝试失败后（从1开始）到下一次尝试之间的睡眠时间
+type Backoff func(attempt int) time.Duration
+
+// ExponentialBackoff
+// 从base开始每次失败后睡眠时间翻倍，最长不超过max，max小于base时固定睡眠base
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max && max >= base {
+			d = max
+		}
+		return d
+	}
+}
+
+// AcquirePolicy
+// 一次加锁的全部重试参数，为0的字段使用客户端的配置
+type AcquirePolicy struct {
+	// 两次尝试之间的睡眠时间，nil时使用 WithBackoffBounds 的随机睡眠
+	Backoff Backoff
+	// 最长等待时间，超过后返回包装了 ErrAcquireTimeout 的错误，和 WithMaxAcquireDuration 取较早的一个
+	MaxDuration time.Duration
+	// 最多尝试的次数，达到后返回包装了 ErrMaxAttempts 的错误
+	// 写锁的最后一次尝试失败时在同一次脚本调用中离开等待队列，只尝试一次的加锁只访问一次redis
+	MaxAttempts int
+	// 单次请求的超时，超时后按网络错误重试；为0时只受ctx限制
+	CommandTimeout time.Duration
+	// 每次睡眠额外加上[0, Jitter)的随机时间
+	Jitter time.Duration
+}
+
+// LockSpec
+// AcquireWith 要获取的锁
+type LockSpec struct {
+	// LockTypeWrite、LockTypeRead 或 LockTypeWeighted
+	Type string
+	Key  string
+	// 持有者的唯一标识，写锁和按权重的读锁必须设置，读锁为空时是匿名读锁
+	Token string
+	// 过期时间，向上取整到秒；为0时写锁使用 DefaultLockExpire，读锁使用配置的读锁过期时间
+	// 写锁小于0时永不过期（同 NoExpire）
+	TTL time.Duration
+	// 按权重的读锁占用的容量
+	Weight int64
+}
+
+// AcquireWith
+// 按照policy获取spec描述的锁，是 Lock、RLock、TryLock 等加锁方法共用的入口
+// 返回的错误同 LockWithPolicy
+func AcquireWith(ctx context.Context, spec LockSpec, policy AcquirePolicy) error {
+	return std.AcquireWith(ctx, spec, policy)
+}
+
+// AcquireWith
+// 同 AcquireWith
+func (c *Client) AcquireWith(ctx context.Context, spec LockSpec, policy AcquirePolicy) error {
+	switch spec.Type {
+	case LockTypeWrite:
+		expireTime := DefaultLockExpire
+		if spec.TTL < 0 {
+			expireTime = NoExpire
+		} else if spec.TTL > 0 {
+			expireTime = ttlSeconds(spec.TTL)
+		}
+		expireTime, err := c.normalizeExpire(spec.Key, expireTime)
+		if err != nil {
+			return err
+		}
+		_, err = c.acquireReply(ctx, spec.Key, spec.Token, LockCmd, expireTime, policy)
+		return err
+	case LockTypeRead:
+		expireTime, err := c.readSpecExpire(spec.TTL)
+		if err != nil {
+			return err
+		}
+		rlockCmd, _, _ := c.readCmds()
+		_, err = c.acquireReply(ctx, spec.Key, spec.Token, rlockCmd, expireTime, policy)
+		return err
+	case LockTypeWeighted:
+		if len(spec.Token) <= 0 {
+			return errors.New("rlock uniqID is nil")
+		}
+		if spec.Weight <= 0 || spec.Weight > c.conf().readCapacity {
+			return errors.New("invalid weight")
+		}
+		expireTime, err := c.readSpecExpire(spec.TTL)
+		if err != nil {
+			return err
+		}
+		_, err = c.acquireReply(ctx, spec.Key, spec.Token, WRLockCmd, expireTime, policy,
+			strconv.FormatInt(spec.Weight, 10), strconv.FormatInt(c.conf().readCapacity, 10))
+		return err
+	default:
+		return errors.New("invalid lock type")
+	}
+}
+
+// readSpecExpire
+// 读锁的过期时间（秒），小于等于0时使用配置的读锁过期时间
+func (c *Client) readSpecExpire(ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return c.conf().readExpire, nil
+	}
+	expireTime := ttlSeconds(ttl)
+	if expireTime > MaxExpire {
+		return 0, ErrInvalidExpire
+	}
+	return expireTime, nil
+}
+
+// ttlSeconds
+// 向上取整到秒，先用浮点比较，避免很长的时间溢出
+func ttlSeconds(ttl time.Duration) int64 {
+	secs := math.Ceil(ttl.Seconds())
+	if secs > float64(MaxExpire) {
+		return MaxExpire + 1
+	}
+	return int64(secs)
+}
+
+// WaitPolicy
+// 加锁的等待限制，两个条件任意一个先满足就停止等待
+// 都为0时一直等待（仍然受ctx和 WithMaxAcquireDuration 的限制）；需要设置更多重试参数时使用 AcquirePolicy
+type WaitPolicy struct {
+	// 最长等待时间，超过后返回包装了 ErrAcquireTimeout 的错误
+	MaxWait time.Duration
+	// 最多尝试的次数，达到后返回包装了 ErrMaxAttempts 的错误
+	MaxAttempts int
+}
+
+// LockWithPolicy
+// 按照policy的限制等待写锁
+// 返回的错误可以用errors.Is区分是哪个限制先到：ErrAcquireTimeout 或 ErrMaxAttempts，
+// 错误信息中带有已经尝试的次数和耗时；放弃时锁还在冷却期内返回 ErrInCooldown
+func LockWithPolicy(ctx context.Context, key, uniqID string, expireTime int64, policy WaitPolicy) error {
+	return std.LockWithPolicy(ctx, key, uniqID, expireTime, policy)
+}
+
+// LockWithPolicy
+// 同 LockWithPolicy
+func (c *Client) LockWithPolicy(ctx context.Context, key, uniqID string, expireTime int64, policy WaitPolicy) error {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return err
+	}
+	spec := LockSpec{Type: LockTypeWrite, Key: key, Token: uniqID, TTL: time.Duration(expireTime) * time.Second}
+	return c.AcquireWith(ctx, spec, AcquirePolicy{MaxDuration: policy.MaxWait, MaxAttempts: policy.MaxAttempts})
+}
+
+// TryLock
+// 在timeout内尝试获取写锁，超时返回false
+// timeout小于等于0时只尝试一次；放弃时锁还在 UnlockWithCooldown 的冷却期内返回false和 ErrInCooldown
+func TryLock(key, uniqID string, expireTime int64, timeout time.Duration) (bool, error) {
+	return std.TryLock(key, uniqID, expireTime, timeout)
+}
+
+// TryLock
+// 同 TryLock
+func (c *Client) TryLock(key, uniqID string, expireTime int64, timeout time.Duration) (bool, error) {
+	policy := WaitPolicy{MaxWait: timeout}
+	if timeout <= 0 {
+		policy = WaitPolicy{MaxAttempts: 1}
+	}
+	err := c.LockWithPolicy(context.Background(), key, uniqID, expireTime, policy)
+	if errors.Is(err, ErrAcquireTimeout) || errors.Is(err, ErrMaxAttempts) {
+		return false, nil
+	}
+	return err == nil, err
+}
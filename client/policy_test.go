@@ -0,0 +1,84 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// 第一次LOCK在脚本执行之后超时，下一次尝试发现锁已经是自己的，直接认为加锁成功，不会一直重试
+func TestCommandTimeoutAfterScriptRan(t *testing.T) {
+	t.Parallel()
+	_, m, _ := rwlocktest.NewTestServer(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer rdb.Close()
+	var locks int32
+	evalSha := func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		ret, err := rdb.EvalSha(context.Background(), sha, keys, args...).Result()
+		if keys[1] == client.LockCmd && atomic.AddInt32(&locks, 1) == 1 {
+			// 脚本已经执行，回馈没有在超时之前送到
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return ret, err
+	}
+	c, err := client.NewClient(&redis.Options{Addr: m.Addr()},
+		client.WithEvictionCheck(client.EvictionIgnore), client.WithEvalSha(evalSha),
+		client.WithBackoffBounds(time.Millisecond, 2*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	policy := client.AcquirePolicy{CommandTimeout: 20 * time.Millisecond, MaxDuration: 2 * time.Second}
+	start := time.Now()
+	if err := c.AcquireWith(context.Background(), writeSpec("order:1", "a"), policy); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("acquire took %s; want it to stop retrying once the lock is ours", elapsed)
+	}
+	if n := atomic.LoadInt32(&locks); n != 2 {
+		t.Fatalf("LOCK calls = %d; want 2", n)
+	}
+	st, err := c.Status(context.Background(), "order:1")
+	if err != nil || st.Owner != "a" {
+		t.Fatalf("Status = %+v, %v; want owner a", st, err)
+	}
+	if n, err := c.WaiterCount("order:1"); err != nil || n != 0 {
+		t.Fatalf("WaiterCount = %d, %v; want 0", n, err)
+	}
+	c.Unlock("order:1", "a")
+	if ok, err := c.TryLock("order:1", "b", 5, 0); !ok || err != nil {
+		t.Fatalf("TryLock after Unlock = %t, %v; want true", ok, err)
+	}
+}
+
+// 初始的随机等待不超过本次加锁的时长限制
+func TestInitialJitterRespectsMaxDuration(t *testing.T) {
+	t.Parallel()
+	holder, m, cleanup := rwlocktest.NewTestServer(t)
+	defer cleanup()
+	holder.Lock("order:1", "a", 30)
+	c, err := client.NewClient(&redis.Options{Addr: m.Addr()},
+		client.WithEvictionCheck(client.EvictionIgnore), client.WithInitialJitter(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	err = c.AcquireWith(context.Background(), writeSpec("order:1", "b"), client.AcquirePolicy{MaxDuration: 50 * time.Millisecond})
+	if !errors.Is(err, client.ErrAcquireTimeout) {
+		t.Fatalf("err = %v; want ErrAcquireTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("acquire returned after %s; want about 50ms", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package client_test
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// 并发加锁的协程数为 benchParallelism*GOMAXPROCS
+const benchParallelism = 16
+
+// benchClient
+// 设置了环境变量 RWLOCK_BENCH_ADDR 时连接这个redis，否则使用miniredis
+// miniredis在进程内执行脚本，只能比较同一台机器上的相对结果，连接池的影响要用真实的redis测
+func benchClient(b *testing.B, options ...client.Option) *client.Client {
+	addr := os.Getenv("RWLOCK_BENCH_ADDR")
+	if len(addr) == 0 {
+		c, _ := rwlocktest.NewTestClient(b, options...)
+		return c
+	}
+	c, err := client.NewClient(&redis.Options{Addr: addr}, options...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { c.Close() })
+	return c
+}
+
+// BenchmarkLockUnlock
+// 加锁再释放的吞吐，按连接池大小和争抢程度（所有协程分摊的key数量，0表示每个协程一个key）分组
+//
+//	RWLOCK_BENCH_ADDR=127.0.0.1:6379 go test ./client -run '^$' -bench LockUnlock -benchtime 2s
+func BenchmarkLockUnlock(b *testing.B) {
+	for _, pool := range []int{2, 8, 32, 128} {
+		for _, keys := range []int{0, 16, 1} {
+			b.Run(fmt.Sprintf("pool=%d/keys=%d", pool, keys), func(b *testing.B) {
+				c := benchClient(b, client.WithPoolSize(pool))
+				benchLockUnlock(b, c, keys)
+			})
+		}
+	}
+}
+
+// benchLockUnlock
+// benchParallelism*GOMAXPROCS个协程在keys个key上加锁再释放，keys为0时每个协程使用自己的key
+func benchLockUnlock(b *testing.B, c *client.Client, keys int) {
+	var seq int64
+	b.SetParallelism(benchParallelism)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := atomic.AddInt64(&seq, 1)
+		id := "bench-" + strconv.FormatInt(n, 10)
+		i := n
+		for pb.Next() {
+			key := id
+			if keys > 0 {
+				key = "bench:" + strconv.FormatInt(i%int64(keys), 10)
+				i++
+			}
+			c.Lock(key, id, 5)
+			c.Unlock(key, id)
+		}
+	})
+}
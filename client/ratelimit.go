@@ -0,0 +1,88 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// 桶的数量超过这个值时清理已经回满的桶
+const limiterSweepSize = 1024
+
+// keyLimiter
+// 按key的令牌桶，限制本客户端访问同一个key的频率
+type keyLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newKeyLimiter
+// 每秒补充rate个令牌，最多攒burst个
+func newKeyLimiter(rate float64, burst int) *keyLimiter {
+	return &keyLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket)}
+}
+
+// reserve
+// 预定key的一个令牌，返回需要等待的时间，0表示可以马上访问
+// 令牌不够时也会扣减，等待的协程按预定的顺序依次拿到令牌
+func (l *keyLimiter) reserve(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if len(l.buckets) > limiterSweepSize {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.refill(now, l.rate, l.burst)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
+
+// cancel
+// 退还reserve预定的一个令牌，用于等待中放弃、没有真正访问redis的情况，避免后面的协程为没有发生的访问多等
+func (l *keyLimiter) cancel(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		return
+	}
+	b.refill(time.Now(), l.rate, l.burst)
+	b.tokens++
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+}
+
+// sweep
+// 清理已经回满的桶，回满的桶和新建的没有区别
+func (l *keyLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		b.refill(now, l.rate, l.burst)
+		if b.tokens >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time, rate, burst float64) {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+}
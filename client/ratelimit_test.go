@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeyLimiterCancelRefunds(t *testing.T) {
+	l := newKeyLimiter(1, 1)
+	if wait := l.reserve("k"); wait != 0 {
+		t.Fatalf("first reserve waits %v; want 0", wait)
+	}
+	first := l.reserve("k")
+	if first <= 900*time.Millisecond || first > time.Second {
+		t.Fatalf("second reserve waits %v; want about 1s", first)
+	}
+	l.cancel("k")
+	if again := l.reserve("k"); again > first {
+		t.Fatalf("reserve after cancel waits %v; want at most %v", again, first)
+	}
+	// 退还不会超过burst
+	l.cancel("k")
+	l.cancel("k")
+	l.cancel("k")
+	if wait := l.reserve("k"); wait != 0 {
+		t.Fatalf("reserve after refunds waits %v; want 0", wait)
+	}
+	if wait := l.reserve("k"); wait == 0 {
+		t.Fatal("refunds went past the burst")
+	}
+}
+
+// 限流等待中放弃的加锁退还令牌：ctx取消和超过最长等待时间两种情况
+func TestAcquireRefundsLimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AcquirePolicy
+		ctx    func() (context.Context, context.CancelFunc)
+	}{
+		{"ctx cancel", AcquirePolicy{}, func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 50*time.Millisecond)
+		}},
+		{"deadline", AcquirePolicy{MaxDuration: 50 * time.Millisecond}, func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClientWithEvalSha(func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+				return `{"opRet":false,"status":"busy"}`, nil
+			}, WithPerKeyRateLimit(1, 1), WithLogger(discardLogger{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 用掉唯一的令牌
+			c.conf().limiter.reserve("k")
+			for i := 0; i < 3; i++ {
+				ctx, cancel := tt.ctx()
+				_, err := c.acquireReply(ctx, "k", "a", LockCmd, 5, tt.policy)
+				cancel()
+				if err == nil {
+					t.Fatal("acquire succeeded")
+				}
+			}
+			// 三次放弃都退还了令牌，只欠最初的一个
+			if wait := c.conf().limiter.reserve("k"); wait > time.Second {
+				t.Fatalf("reserve waits %v after abandoned acquires; want at most 1s", wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package client_test
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// waitForWriter
+// 等到key的等待队列中有写者
+func waitForWriter(t *testing.T, c *client.Client, key string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if n, err := c.WaiterCount(key); err == nil && n > 0 {
+			return
+		}
+	}
+	t.Fatal("the writer never queued")
+}
+
+func tryRLock(t *testing.T, c *client.Client, key, id string) client.RLockResult {
+	t.Helper()
+	res, err := c.TryRLock(key, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestReadBatchAdmission(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t, client.WithReadBatch(2))
+	const key = "order:1"
+
+	c.RLockID(key, "r0")
+	done := make(chan struct{})
+	go func() {
+		c.Lock(key, "w", 5)
+		close(done)
+	}()
+	waitForWriter(t, c, key)
+
+	// 写者排队之后只再放行一批（2个）读者
+	for _, id := range []string{"r1", "r2"} {
+		if res := tryRLock(t, c, key, id); !res.Acquired {
+			t.Fatalf("reader %s refused inside the batch: %+v", id, res)
+		}
+	}
+	if res := tryRLock(t, c, key, "r3"); res.Acquired || res.PendingWriters != 1 {
+		t.Fatalf("reader r3 = %+v; want refused with one pending writer", res)
+	}
+
+	for _, id := range []string{"r0", "r1", "r2"} {
+		c.RUnlockID(key, id)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the writer did not get the lock after the batch drained")
+	}
+	c.Unlock(key, "w")
+	if res := tryRLock(t, c, key, "r3"); !res.Acquired {
+		t.Fatalf("reader r3 after the writer = %+v; want admitted", res)
+	}
+}
+
+// 默认读优先，写者排队时新的读者照样加锁
+func TestWithoutReadBatchReadersFirst(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	const key = "order:1"
+
+	c.RLockID(key, "r0")
+	done := make(chan struct{})
+	go func() {
+		c.Lock(key, "w", 5)
+		close(done)
+	}()
+	waitForWriter(t, c, key)
+
+	for i := 1; i <= 5; i++ {
+		if res := tryRLock(t, c, key, "r"+strconv.Itoa(i)); !res.Acquired {
+			t.Fatalf("reader %d refused without a read batch: %+v", i, res)
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		c.RUnlockID(key, "r"+strconv.Itoa(i))
+	}
+	<-done
+	c.Unlock(key, "w")
+}
+
+// BenchmarkReadBatch
+// 8个协程不停地加读锁时，写者一次加锁再释放的耗时（ns/op）以及同时完成的读锁数量（reads/op）
+// batch=0 是默认的读优先，batch越大读的吞吐越高、写者等待越久
+func BenchmarkReadBatch(b *testing.B) {
+	for _, batch := range []int{0, 4, 64} {
+		b.Run(fmt.Sprintf("batch=%d", batch), func(b *testing.B) {
+			c := benchClient(b, client.WithReadBatch(batch))
+			const key = "bench:readbatch"
+			var reads int64
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(id string) {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						c.RLockID(key, id)
+						time.Sleep(time.Millisecond)
+						c.RUnlockID(key, id)
+						atomic.AddInt64(&reads, 1)
+					}
+				}("reader-" + strconv.Itoa(i))
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Lock(key, "writer", 5)
+				c.Unlock(key, "writer")
+			}
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+			b.ReportMetric(float64(atomic.LoadInt64(&reads))/float64(b.N), "reads/op")
+		})
+	}
+}
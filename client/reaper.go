@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Reaper 默认的检查间隔
+const DefaultReapInterval = time.Minute
+
+// 清理孤儿锁的方式
+type ReapMode int
+
+const (
+	// 只报告，不修改锁
+	ReapReport ReapMode = iota
+	// 释放已经死亡的持有者的写锁，移除死亡的读者
+	ReapActive
+)
+
+// Orphan
+// Reaper 发现的一个持有者已经死亡的锁
+type Orphan struct {
+	Key   string
+	Owner string
+	// 为true时是读者，否则是写锁的持有者
+	Reader bool
+	// ReapActive 模式下是否已经释放
+	Reaped bool
+	// 释放失败的错误
+	Err error
+}
+
+// Reaper
+// 定期用SCAN找出所有的锁，对写锁的持有者和读者调用alive判断它是否还活着（例如查询服务注册中心）
+// 死亡的持有者交给 OnOrphan 报告，ReapActive 模式下同时释放它的写锁、移除它的读锁
+// 只会释放仍然由死亡的持有者持有的锁，锁在检查之后已经换了持有者时不受影响
+type Reaper struct {
+	client   *Client
+	interval time.Duration
+	alive    func(owner string) bool
+	mode     ReapMode
+	// 要检查的锁，同 ListLocks 的pattern，为空时检查所有的锁；Run 之前设置
+	Pattern string
+	// 每发现一个孤儿锁调用一次，nil时只打印日志；Run 之前设置
+	OnOrphan func(Orphan)
+}
+
+// NewReaper
+// 使用默认客户端创建 Reaper，每隔interval检查一次，interval小于等于0时使用 DefaultReapInterval
+func NewReaper(interval time.Duration, alive func(owner string) bool, mode ReapMode) *Reaper {
+	return std.NewReaper(interval, alive, mode)
+}
+
+// NewReaper
+// 同 NewReaper
+func (c *Client) NewReaper(interval time.Duration, alive func(owner string) bool, mode ReapMode) *Reaper {
+	if interval <= 0 {
+		interval = DefaultReapInterval
+	}
+	return &Reaper{client: c, interval: interval, alive: alive, mode: mode}
+}
+
+// Run
+// 每隔interval检查一次，直到ctx取消，返回ctx.Err()
+// 单次检查出错时打印日志，下一个周期继续
+func (r *Reaper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.ReapOnce(ctx); err != nil && ctx.Err() == nil {
+			logWith(r.client.conf().logger, Fields{FieldOp: "reap"}).Printf("reap %s: %v", r.Pattern, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReapOnce
+// 检查一次，返回发现的孤儿锁
+// 某个key查询状态失败时跳过它继续检查其他的key，最后和发现的孤儿锁一起返回错误；ctx取消时立即返回
+// 每个锁最多检查 Status 列出的100个读者，匿名读锁和 WithSharedReadTTL 模式下的读者没有ID，不会检查
+func (r *Reaper) ReapOnce(ctx context.Context) ([]Orphan, error) {
+	c := r.client
+	if c.conn() == nil {
+		return nil, ErrNotInitialized
+	}
+	keys, err := c.scanLockKeys(ctx, r.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []Orphan
+	// 查询状态失败的key跳过，检查完其他的key后返回第一个错误
+	var statusErr error
+	failed := 0
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return orphans, err
+		}
+		st, err := c.Status(ctx, key)
+		if err != nil {
+			if failed++; statusErr == nil {
+				statusErr = fmt.Errorf("status %s: %w", key, err)
+			}
+			continue
+		}
+		if len(st.Owner) > 0 && !r.alive(st.Owner) {
+			orphans = append(orphans, r.reap(ctx, Orphan{Key: key, Owner: st.Owner}))
+		}
+		for _, reader := range st.ReaderList {
+			if !r.alive(reader.ID) {
+				orphans = append(orphans, r.reap(ctx, Orphan{Key: key, Owner: reader.ID, Reader: true}))
+			}
+		}
+	}
+	if statusErr != nil {
+		return orphans, fmt.Errorf("%d of %d keys not checked, first: %w", failed, len(keys), statusErr)
+	}
+	return orphans, nil
+}
+
+// reap
+// ReapActive 模式下释放孤儿锁，然后报告
+// 写锁用持有者自己的uniqID释放，脚本只会删除仍然由它持有的锁
+func (r *Reaper) reap(ctx context.Context, o Orphan) Orphan {
+	c := r.client
+	if r.mode == ReapActive {
+		if o.Reader {
+			o.Reaped, o.Err = c.sendOnce(ctx, o.Key, o.Owner, EvictReaderCmd, 0)
+		} else {
+			var res *responseLock
+			res, o.Err = c.sendLock(withFullReply(ctx), o.Key, o.Owner, UnlockCmd, 0)
+			if o.Err != nil {
+				c.handleErrorContext(ctx, o.Err)
+			} else if res.IsError() {
+				o.Err = replyError(res)
+			} else {
+				o.Reaped = res.Owner == o.Owner
+			}
+		}
+	}
+	if r.OnOrphan != nil {
+		r.OnOrphan(o)
+		return o
+	}
+	logWith(c.conf().logger, Fields{FieldKey: o.Key, FieldOp: "reap", FieldToken: o.Owner}).
+		Printf("orphan lock %s held by %s (reader %t, reaped %t, err %v)", o.Key, o.Owner, o.Reader, o.Reaped, o.Err)
+	return o
+}
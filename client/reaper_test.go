@@ -0,0 +1,132 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+func isAlive(owner string) bool { return strings.HasPrefix(owner, "alive") }
+
+// setupOrphans
+// job:1 由死亡的持有者加写锁，job:2 由活着的持有者加写锁，job:3 有一个死亡的读者和一个活着的读者
+func setupOrphans(c *client.Client) {
+	c.Lock("job:1", "dead-w", 30)
+	c.Lock("job:2", "alive-w", 30)
+	c.RLockID("job:3", "dead-r")
+	c.RLockID("job:3", "alive-r")
+}
+
+func sortOrphans(orphans []client.Orphan) {
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Key < orphans[j].Key })
+}
+
+func TestReapReport(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	setupOrphans(c)
+	var reported []client.Orphan
+	r := c.NewReaper(0, isAlive, client.ReapReport)
+	r.Pattern = "job:*"
+	r.OnOrphan = func(o client.Orphan) { reported = append(reported, o) }
+
+	orphans, err := r.ReapOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortOrphans(orphans)
+	if len(orphans) != 2 || len(reported) != 2 {
+		t.Fatalf("orphans = %+v, reported %d; want 2", orphans, len(reported))
+	}
+	if o := orphans[0]; o.Key != "job:1" || o.Owner != "dead-w" || o.Reader || o.Reaped {
+		t.Fatalf("writer orphan = %+v", o)
+	}
+	if o := orphans[1]; o.Key != "job:3" || o.Owner != "dead-r" || !o.Reader || o.Reaped {
+		t.Fatalf("reader orphan = %+v", o)
+	}
+	// 只报告，不修改锁
+	if st, _ := c.Status(context.Background(), "job:1"); st.Owner != "dead-w" {
+		t.Fatalf("report mode released job:1: %+v", st)
+	}
+	if st, _ := c.Status(context.Background(), "job:3"); st.Readers != 2 {
+		t.Fatalf("report mode evicted a reader: %+v", st)
+	}
+}
+
+func TestReapActive(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	setupOrphans(c)
+	r := c.NewReaper(0, isAlive, client.ReapActive)
+	r.Pattern = "job:*"
+	r.OnOrphan = func(client.Orphan) {}
+
+	orphans, err := r.ReapOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 2 {
+		t.Fatalf("orphans = %+v; want 2", orphans)
+	}
+	for _, o := range orphans {
+		if !o.Reaped || o.Err != nil {
+			t.Fatalf("orphan %+v was not reaped", o)
+		}
+	}
+	ctx := context.Background()
+	if st, _ := c.Status(ctx, "job:1"); st.Owner != "" {
+		t.Fatalf("job:1 still held: %+v", st)
+	}
+	if st, _ := c.Status(ctx, "job:2"); st.Owner != "alive-w" {
+		t.Fatalf("job:2 of a live owner was touched: %+v", st)
+	}
+	st, _ := c.Status(ctx, "job:3")
+	if len(st.ReaderList) != 1 || st.ReaderList[0].ID != "alive-r" {
+		t.Fatalf("job:3 readers = %+v; want only alive-r", st.ReaderList)
+	}
+
+	// 再检查一次没有新的孤儿锁
+	if orphans, err := r.ReapOnce(ctx); err != nil || len(orphans) != 0 {
+		t.Fatalf("second pass = %+v, %v; want nothing", orphans, err)
+	}
+}
+
+// 单个key查询状态失败时跳过它，其他key照常检查，最后返回错误
+func TestReapContinuesAfterStatusError(t *testing.T) {
+	t.Parallel()
+	_, m, _ := rwlocktest.NewTestServer(t)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer rdb.Close()
+	errBroken := errors.New("broken node")
+	evalSha := func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		if keys[0] == "job:0" && keys[1] == client.StatusCmd {
+			return nil, errBroken
+		}
+		return rdb.EvalSha(ctx, sha, keys, args...).Result()
+	}
+	c, err := client.NewClient(&redis.Options{Addr: m.Addr()},
+		client.WithEvictionCheck(client.EvictionIgnore), client.WithEvalSha(evalSha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Lock("job:0", "dead-0", 30)
+	setupOrphans(c)
+	r := c.NewReaper(0, isAlive, client.ReapReport)
+	r.Pattern = "job:*"
+	r.OnOrphan = func(client.Orphan) {}
+
+	orphans, err := r.ReapOnce(context.Background())
+	if !errors.Is(err, errBroken) || !strings.Contains(err.Error(), "job:0") {
+		t.Fatalf("err = %v; want the job:0 status error", err)
+	}
+	if len(orphans) != 2 {
+		t.Fatalf("orphans = %+v; want the 2 orphans on the other keys", orphans)
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+)
+
+type countMetrics struct {
+	NopMetrics
+	reconnects, reloads int32
+}
+
+func (m *countMetrics) IncReconnect()    { atomic.AddInt32(&m.reconnects, 1) }
+func (m *countMetrics) IncScriptReload() { atomic.AddInt32(&m.reloads, 1) }
+
+func newReconnectClient(t *testing.T) (*Client, *miniredis.Miniredis, *countMetrics) {
+	m := miniredis.RunT(t)
+	metrics := &countMetrics{}
+	c, err := NewClient(&redis.Options{Addr: m.Addr()}, WithEvictionCheck(EvictionIgnore), WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, m, metrics
+}
+
+func flushScripts(t *testing.T, m *miniredis.Miniredis) {
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer rdb.Close()
+	if err := rdb.ScriptFlush(context.Background()).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lockAll
+// n个协程同时加锁再释放
+func lockAll(t *testing.T, c *Client, n int) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := string(rune('a' + i))
+			if err := c.LockUntil(context.Background(), "reconnect", id); err != nil {
+				t.Error(err)
+				return
+			}
+			c.Unlock("reconnect", id)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestReconnectLoadsScript(t *testing.T) {
+	c, m, metrics := newReconnectClient(t)
+	old := c.Redis()
+	flushScripts(t, m)
+
+	if err := c.handleEofError(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Redis() == old {
+		t.Fatal("reconnect kept the old client")
+	}
+	// 重连时已经加载了脚本，之后的EvalSha不会遇到NOSCRIPT
+	lockAll(t, c, 8)
+	if n := atomic.LoadInt32(&metrics.reloads); n != 0 {
+		t.Fatalf("script reloads = %d, want 0", n)
+	}
+	// 旧的客户端已经关闭
+	if err := old.Ping(context.Background()).Err(); err != redis.ErrClosed {
+		t.Fatalf("old client ping = %v, want %v", err, redis.ErrClosed)
+	}
+}
+
+func TestNoScriptReloadsOnce(t *testing.T) {
+	c, m, metrics := newReconnectClient(t)
+	flushScripts(t, m)
+	lockAll(t, c, 8)
+	if n := atomic.LoadInt32(&metrics.reloads); n != 1 {
+		t.Fatalf("script reloads = %d, want 1", n)
+	}
+}
+
+func TestReconnectFailureKeepsClient(t *testing.T) {
+	c, m, _ := newReconnectClient(t)
+	old := c.Redis()
+	m.Close()
+	if err := c.handleEofError(); err == nil {
+		t.Fatal("reconnect succeeded while redis is down")
+	}
+	// 重连失败时继续使用旧的客户端
+	if c.Redis() != old {
+		t.Fatal("client replaced by a failed reconnect")
+	}
+}
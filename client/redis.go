@@ -2,228 +2,684 @@ package client
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	redis "github.com/go-redis/redis/v8"
 	"github.com/lzw5399/rwlock/lua"
-	"github.com/lzw5399/rwlock/tool"
 )
 
+// Redis
+// 默认客户端当前使用的redis连接，只用于兼容直接使用它的调用方，客户端内部不会读取
 var Redis redis.UniversalClient
-var opts interface{}
 
 // error 定义
 const NoScriptError = "NOSCRIPT No matching script. Please use EVAL."
 const EofError = "EOF"
 
+// 集群模式下可重试的错误前缀
+var clusterRetryPrefixes = []string{"MOVED ", "ASK ", "TRYAGAIN", "CLUSTERDOWN"}
+
 // 锁的相关指令
 const LockCmd = "LOCK"
 const UnlockCmd = "UNLOCK"
 const RLockCmd = "RLOCK"
 const RUnlockCmd = "RUNLOCK"
+const StatusCmd = "STATUS"
+const WaitersCmd = "WAITERS"
+const UpgradeCmd = "UPGRADE"
+const ExtendCmd = "EXTEND"
+const ReacquireCmd = "REACQUIRE"
+const LeaveCmd = "LEAVE"
+const RExtendCmd = "REXTEND"
+const LockIfCmd = "LOCKIF"
+const WRLockCmd = "WRLOCK"
+const HandoffCmd = "HANDOFF"
+const WRUnlockCmd = "WRUNLOCK"
+const GCCmd = "GC"
+const RLockGetCmd = "RLOCKGET"
+const LockGetCmd = "LOCKGET"
+const SRLockCmd = "SRLOCK"
+const SRUnlockCmd = "SRUNLOCK"
+const SRExtendCmd = "SREXTEND"
+const FreezeCmd = "FREEZE"
+const EvictReaderCmd = "EVICTREADER"
+const ShortenCmd = "SHORTEN"
+const MigrateCmd = "MIGRATE"
 
-var shaHashID string
+// DoInit
+// 初始化默认客户端
+func DoInit(optObj interface{}, options ...Option) error {
+	c := defaultConfig()
+	for _, o := range options {
+		o(c)
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
+	std.cfg.Store(c)
+	// 重新初始化时退出排空状态，并清空本地持有的锁的记录
+	atomic.StoreInt32(&std.draining, 0)
+	std.held.reset()
+	return std.connect(optObj)
+}
 
-func DoInit(optObj interface{}) error {
+// connect
+// 按照redis的配置创建客户端并加载Lua脚本
+func (c *Client) connect(optObj interface{}) error {
+	old := c.conn()
+	var rdb redis.UniversalClient
+	// 连接池的设置作用在副本上，不修改调用方传入的配置
 	switch opt := optObj.(type) {
 	case *redis.Options:
-		Redis = redis.NewClient(opt)
+		o := *opt
+		c.conf().pool.apply(&o.PoolSize, &o.MinIdleConns)
+		rdb = redis.NewClient(&o)
 	case *redis.FailoverOptions:
-		Redis = redis.NewFailoverClient(opt)
+		o := *opt
+		c.conf().pool.apply(&o.PoolSize, &o.MinIdleConns)
+		rdb = redis.NewFailoverClient(&o)
 	case *redis.ClusterOptions:
-		Redis = redis.NewClusterClient(opt)
+		// 集群模式下是每个节点的连接池
+		o := *opt
+		c.conf().pool.apply(&o.PoolSize, &o.MinIdleConns)
+		rdb = redis.NewClusterClient(&o)
 	default:
 		return errors.New("unsupported options")
 	}
-	if _, err := Redis.Ping(context.Background()).Result(); err != nil {
-		return err
+	c.opts = optObj
+	// 先在新的客户端上加载好脚本、检查淘汰策略再切换，重连后其他协程的第一次EvalSha不会遇到NOSCRIPT
+	var hashID string
+	ready := func() error {
+		if _, err := rdb.Ping(context.Background()).Result(); err != nil {
+			return err
+		}
+		var err error
+		if hashID, err = scriptLoad(context.Background(), rdb); err != nil {
+			return err
+		}
+		if err := c.checkEviction(context.Background(), rdb); err != nil {
+			return err
+		}
+		// 订阅成功后才替换旧的订阅，失败时旧的订阅不受影响
+		if c.conf().notify {
+			return c.notify.start(rdb)
+		}
+		return nil
 	}
-	opts = optObj
-
-	if err := LoadLua(); err != nil {
+	if err := ready(); err != nil {
+		if old != nil {
+			// 重连失败时继续使用旧的客户端，关闭新建的，避免每次失败的重连泄漏一个连接池
+			_ = rdb.Close()
+			return err
+		}
+		// 第一次初始化失败时仍然使用新的客户端，之后的重试和重连使用它
+		c.setConn(rdb, hashID)
 		return err
 	}
+	c.setConn(rdb, hashID)
+	if !c.conf().notify {
+		c.notify.stop()
+	}
+	// 按前缀的观察者在新的客户端上重新订阅
+	c.watches.restart(rdb, c.conn, c.conf().logger)
+	// 切换后关闭旧的客户端，避免连接池泄漏
+	if old != nil {
+		_ = old.Close()
+	}
 	return nil
 }
 
-// 加载 Lua脚本
+// conn
+// 当前使用的redis客户端，重连时会被其他协程替换
+func (c *Client) conn() redis.UniversalClient {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.redis
+}
+
+// sha
+// 当前加载的脚本hash
+func (c *Client) sha() string {
+	c.connMu.RLock()
+	defer c.connMu.RUnlock()
+	return c.shaHashID
+}
+
+// setConn
+// 同时切换redis客户端和脚本hash
+func (c *Client) setConn(rdb redis.UniversalClient, hashID string) {
+	c.connMu.Lock()
+	c.redis = rdb
+	c.shaHashID = hashID
+	c.connMu.Unlock()
+	if c == std {
+		Redis = rdb
+	}
+}
+
+// setSha
+// 重新加载脚本后更新hash
+func (c *Client) setSha(hashID string) {
+	c.connMu.Lock()
+	c.shaHashID = hashID
+	c.connMu.Unlock()
+}
+
+// LoadLua
+// 默认客户端加载Lua脚本
 func LoadLua() error {
-	hashID, err := Redis.ScriptLoad(context.Background(), lua.ScriptContent).Result()
+	return std.LoadLua()
+}
+
+// LoadLua
+// 加载 Lua脚本
+// 集群模式下ClusterClient的SCRIPT LOAD会在每个分片（主从节点）上执行，任意一个节点失败都会返回错误
+func (c *Client) LoadLua() error {
+	return c.loadLua(context.Background())
+}
+
+// loadLua
+// 同 LoadLua，使用ctx加载
+func (c *Client) loadLua(ctx context.Context) error {
+	rdb := c.conn()
+	if rdb == nil {
+		return ErrNotInitialized
+	}
+	hashID, err := scriptLoad(ctx, rdb)
 	if err != nil {
 		return err
 	}
 	// 保存hashID
-	SetShaHasID(hashID)
+	c.setSha(hashID)
 	return nil
 
 }
+
+// scriptLoad
+// 在rdb上加载脚本，返回脚本的hash
+func scriptLoad(ctx context.Context, rdb redis.UniversalClient) (string, error) {
+	if len(lua.ScriptContent) <= 0 {
+		return "", ErrEmptyScript
+	}
+	hashID, err := rdb.ScriptLoad(ctx, lua.ScriptContent).Result()
+	if err != nil {
+		return "", err
+	}
+	// redis返回的hash必须是脚本内容的SHA1，否则之后的EvalSha都会失败
+	if expect := scriptSHA1(); hashID != expect {
+		return "", fmt.Errorf("%w: got %q, expect %q", ErrScriptHash, hashID, expect)
+	}
+	return hashID, nil
+}
+
+// scriptSHA1
+// 脚本内容的SHA1
+func scriptSHA1() string {
+	sum := sha1.Sum([]byte(lua.ScriptContent))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetShaHashID() string {
-	return shaHashID
+	return std.sha()
 }
 func SetShaHasID(str string) {
-	shaHashID = str
+	std.setSha(str)
 }
 
 // responseLock
 // 收到redis的指令回馈
 type responseLock struct {
-	OpRet  bool   `json:"opRet"`
-	ErrMsg string `json:"errMsg"`
-	Debug  string `json:"debug"`
+	OpRet   bool   `json:"opRet"`
+	ErrMsg  string `json:"errMsg"`
+	Debug   string `json:"debug"`
+	Version string `json:"version"`
+	Owner   string `json:"owner"`
+	TTL     int64  `json:"ttl"`
+	Readers int    `json:"readers"`
+	Waiters int    `json:"waiters"`
+	// 在等待队列中的位置，从1开始，0表示不在队列中
+	Position int `json:"position"`
+	// 写锁附带的元数据
+	Meta string `json:"meta"`
+	// 写锁持有者加锁时附带的trace ID，只有STATUS返回
+	Trace string `json:"trace"`
+	// 未过期的读者，只有STATUS返回
+	ReaderList []readerReply `json:"readerList"`
+	// RLOCKGET/LOCKGET读取到的值
+	Value string `json:"value"`
+	// 接管了过期未释放的锁时，上一个持有者的uniqID和元数据
+	PrevOwner string `json:"prevOwner"`
+	PrevMeta  string `json:"prevMeta"`
+	// 加写锁成功时分配的fencing token
+	Fence int64 `json:"fence"`
+	// 因为冷却期加锁失败时，冷却期剩余的毫秒数
+	Cooldown int64 `json:"cooldown"`
+	// 还有没清理完的过期记录
+	More bool `json:"more"`
+	// 本次清理的过期记录数量
+	Cleaned int    `json:"cleaned"`
+	Status  string `json:"status"`
 }
 
-func (r responseLock) IsError() bool {
+// readerReply
+// 脚本返回的读者，ttl为剩余毫秒数
+type readerReply struct {
+	ID  string `json:"id"`
+	TTL int64  `json:"ttl"`
+}
+
+// 脚本返回的状态
+// ok: 成功；busy: 锁被占用，可以重试；error: 致命错误，不要重试
+const StatusOK = "ok"
+const StatusBusy = "busy"
+const StatusError = "error"
+
+// State
+// 返回脚本的状态，旧版本脚本没有status字段时根据其他字段推断
+func (r responseLock) State() string {
+	if len(r.Status) > 0 {
+		return r.Status
+	}
 	if len(r.ErrMsg) > 0 {
-		return true
+		return StatusError
 	}
-	return false
+	if r.OpRet {
+		return StatusOK
+	}
+	return StatusBusy
+}
+
+func (r responseLock) IsError() bool {
+	return r.State() == StatusError
+}
+func (r responseLock) IsBusy() bool {
+	return r.State() == StatusBusy
 }
 func (r responseLock) Success() bool {
-	return r.OpRet
+	return r.State() == StatusOK
 }
 func (r responseLock) Error() string {
 	return r.ErrMsg
 }
 
+// String
+// 只输出排查问题常用的字段
+func (r responseLock) String() string {
+	return fmt.Sprintf("status=%s owner=%q readers=%d waiters=%d errMsg=%q debug=%q",
+		r.State(), r.Owner, r.Readers, r.Waiters, r.ErrMsg, r.Debug)
+}
+
 // Lock
 // 写锁
+// expireTime传入 NoExpire 时锁永不过期，其他小于等于0的值会被替换成 DefaultLockExpire 并打印警告
+// expireTime超过 MaxExpire 时panic(ErrInvalidExpire)
+// 超过 WithMaxAcquireDuration 设置的时长仍未拿到锁时panic(ErrAcquireTimeout)
 func Lock(key string, uniqID string, expireTime int64) {
-	if len(key) < 0 {
+	std.Lock(key, uniqID, expireTime)
+}
+
+// Lock
+// 同 Lock
+func (c *Client) Lock(key string, uniqID string, expireTime int64) {
+	if len(key) <= 0 {
 		panic("lock key is nil")
 	}
-	if expireTime <= 0 {
-		expireTime = 5
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		panic(err)
 	}
-	for {
-		res, err := sendLock(GetShaHashID(), key, uniqID, LockCmd, expireTime)
-		if err != nil {
-			handleError(err)
-			time.Sleep(getRandomSleepTime())
-			continue
-		}
-		if res != nil && res.IsError() {
-			panic(res.Error())
-		}
-		if res != nil && res.Success() {
-			return
-		}
+	spec := LockSpec{Type: LockTypeWrite, Key: key, Token: uniqID, TTL: time.Duration(expireTime) * time.Second}
+	if err := c.AcquireWith(context.Background(), spec, AcquirePolicy{}); err != nil {
+		panic(err)
+	}
+}
 
-		time.Sleep(getRandomSleepTime())
+// normalizeExpire
+// 校验写锁的过期时间
+// 小于等于0的值替换成默认值并打印警告，超过 MaxExpire 返回 ErrInvalidExpire
+func (c *Client) normalizeExpire(key string, expireTime int64) (int64, error) {
+	if expireTime > MaxExpire {
+		return 0, ErrInvalidExpire
+	}
+	if expireTime == NoExpire || expireTime > 0 {
+		return expireTime, nil
 	}
+	logWith(c.conf().logger, Fields{FieldKey: key}).Printf("lock %s: invalid expireTime %d, use default %ds (pass NoExpire for a persistent lock)", key, expireTime, DefaultLockExpire)
+	return DefaultLockExpire, nil
 }
 
 // Unlock
 // 写锁的释放
 func Unlock(key, uniqID string) {
+	std.Unlock(key, uniqID)
+}
+
+// Unlock
+// 同 Unlock
+func (c *Client) Unlock(key, uniqID string) {
+	if err, fatal := c.unlock(context.Background(), key, uniqID); fatal {
+		panic(err)
+	}
+}
+
+// UnlockE
+// 同 Unlock，出错时返回错误而不是panic
+// 锁已经过期、被别人持有时，WithUnlockExpiredPolicy 设置为 UnlockExpiredError 的返回 ErrLockExpired，默认忽略返回nil
+func UnlockE(key, uniqID string) error {
+	return std.UnlockE(key, uniqID)
+}
+
+// UnlockE
+// 同 UnlockE
+func (c *Client) UnlockE(key, uniqID string) error {
+	err, _ := c.unlock(withFullReply(context.Background()), key, uniqID)
+	return err
+}
+
+// unlock
+// 释放写锁，网络错误时重试，重试用完后返回最后一次的错误
+// fatal表示脚本报错、回馈无法解析等重试也不会成功的错误，Unlock 遇到时panic
+// 能拿到完整回馈、并且设置了 UnlockExpiredError 时，锁已经不是自己的返回 ErrLockExpired
+// extra为追加的脚本参数
+func (c *Client) unlock(ctx context.Context, key, uniqID string, extra ...string) (err error, fatal bool) {
+	defer c.held.remove(key, uniqID, true)
+	defer recordLockReleased(key)
 	i := 10
 	for {
-		res, err := sendLock(GetShaHashID(), key, uniqID, UnlockCmd, 0)
+		var res *responseLock
+		res, err = c.sendLock(ctx, key, uniqID, UnlockCmd, 0, extra...)
 		if res != nil && res.Success() {
-			return
+			// 锁已经不存在或被别人持有时脚本同样返回成功，只有真正释放时Owner才是自己
+			released := res.Owner != "" && res.Owner == uniqID
+			if released {
+				c.emitEvent(EventReleased, key, uniqID, res.Meta, 0)
+			}
+			// 整数回馈中没有Owner，无法判断
+			if !released && c.conf().unlockExpired == UnlockExpiredError && !c.useCompactReply(ctx, UnlockCmd) {
+				return ErrLockExpired, false
+			}
+			return nil, false
 		}
 		if res != nil && res.IsError() {
-			panic(res.Error())
+			return errors.New(res.Error()), true
+		}
+		if errors.Is(err, ErrMalformedReply) || err == ErrNotInitialized {
+			return err, true
 		}
 		if err != nil {
-			handleError(err)
+			c.handleError(err)
 		}
 		if i--; i <= 0 {
-			return
+			return err, false
 		}
-		time.Sleep(getRandomSleepTime())
+		time.Sleep(c.getRandomSleepTime())
 	}
 }
 
 // RLock
-// 读锁
+// 匿名读锁，只有计数，不会过期，释放时调用 RUnlock
+// 超过 WithMaxAcquireDuration 设置的时长仍未拿到锁时panic(ErrAcquireTimeout)
 func RLock(key string) {
-	for {
-		res, err := sendLock(GetShaHashID(), key, "", RLockCmd, 0)
-		if res != nil && res.Success() {
-			return
-		}
-		if err != nil {
-			handleError(err)
-		}
+	std.RLock(key)
+}
+
+// RLock
+// 同 RLock
+func (c *Client) RLock(key string) {
+	c.RLockID(key, "")
+}
 
-		time.Sleep(getRandomSleepTime())
+// RLockID
+// 读锁，按读锁的默认过期时间登记读者uniqID，释放时调用 RUnlockID
+// 同一个uniqID重复加读锁是可重入的，需要调用相同次数的 RUnlockID 才会释放；uniqID为空时同 RLock
+// 超过 WithMaxAcquireDuration 设置的时长仍未拿到锁时panic(ErrAcquireTimeout)
+func RLockID(key, uniqID string) {
+	std.RLockID(key, uniqID)
+}
+
+// RLockID
+// 同 RLockID
+func (c *Client) RLockID(key, uniqID string) {
+	if err := c.AcquireWith(context.Background(), LockSpec{Type: LockTypeRead, Key: key, Token: uniqID}, AcquirePolicy{}); err != nil {
+		panic(err)
 	}
 }
 
+// RLockN
+// 同 RLock，返回加锁成功时的读者数量（包括自己），只用于日志等展示
+// 同一个uniqID重入时不会重复计数
+func RLockN(key, uniqID string) (int, error) {
+	return std.RLockN(key, uniqID)
+}
+
+// RLockN
+// 同 RLockN
+func (c *Client) RLockN(key, uniqID string) (int, error) {
+	rlockCmd, _, _ := c.readCmds()
+	res, err := c.acquireReply(withFullReply(context.Background()), key, uniqID, rlockCmd, c.conf().readExpire, AcquirePolicy{})
+	if err != nil {
+		return 0, err
+	}
+	return res.Readers, nil
+}
+
 // RUnlock
-// 释放读锁
+// 释放匿名读锁
 func RUnlock(key string) {
+	std.RUnlock(key)
+}
+
+// RUnlock
+// 同 RUnlock
+func (c *Client) RUnlock(key string) {
+	c.RUnlockID(key, "")
+}
+
+// RUnlockID
+// 释放 RLockID 等带uniqID的读锁
+func RUnlockID(key, uniqID string) {
+	std.RUnlockID(key, uniqID)
+}
+
+// RUnlockID
+// 同 RUnlockID
+func (c *Client) RUnlockID(key, uniqID string) {
 	if len(key) <= 0 {
 		panic("runlock nil key")
 	}
+	if err := c.runlock(key, uniqID); err == ErrNotInitialized {
+		panic(err)
+	}
+}
+
+// runlock
+// 释放读锁，网络错误时重试，重试用完后返回最后一次的错误
+// 释放未加锁或已过期的读锁时返回脚本的错误，重试也没有意义
+func (c *Client) runlock(key, uniqID string) error {
+	defer c.held.remove(key, uniqID, false)
+	_, runlockCmd, _ := c.readCmds()
 	i := 10
 	for {
-		res, err := sendLock(GetShaHashID(), key, "", RUnlockCmd, 0)
+		res, err := c.sendLock(context.Background(), key, uniqID, runlockCmd, 0)
 		if res != nil && res.Success() {
-			return
+			return nil
+		}
+		if res != nil && res.IsError() {
+			return replyError(res)
+		}
+		if errors.Is(err, ErrMalformedReply) || err == ErrNotInitialized {
+			return err
 		}
 		if err != nil {
-			handleError(err)
+			c.handleError(err)
 		}
 
 		if i--; i <= 0 {
-			return
+			return err
 		}
-		time.Sleep(getRandomSleepTime())
+		time.Sleep(c.getRandomSleepTime())
 	}
 }
 
 // getRandomSleepTime
 // 随机 睡眠时间
-// 10 - 20 ms
-func getRandomSleepTime() time.Duration {
-	return time.Duration(tool.Rand(10, 20)) * time.Millisecond
+// 默认 10 - 20 ms，可以通过 WithBackoffBounds 修改
+func (c *Client) getRandomSleepTime() time.Duration {
+	min, max := c.conf().backoffMin, c.conf().backoffMax
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(c.conf().rand.Int63n(int64(max-min)))
 }
 
 // sendLock
 // 发送封装并发送锁指令
-func sendLock(shaHashID, key string, uniqID, lockCmd string, expireTime int64) (*responseLock, error) {
+// 参数依次为 ARGV[1]=uniqID ARGV[2]=expireTime，extra追加在后面，用不到expireTime的指令传0即可
+func (c *Client) sendLock(ctx context.Context, key string, uniqID, lockCmd string, expireTime int64, extra ...string) (*responseLock, error) {
+	c.connMu.RLock()
+	rdb, sha := c.redis, c.shaHashID
+	c.connMu.RUnlock()
+	if rdb == nil && c.conf().evalSha == nil {
+		return nil, ErrNotInitialized
+	}
+	args := make([]interface{}, 0, 2+len(extra))
+	args = append(args, uniqID, strconv.FormatInt(expireTime, 10))
+	for _, e := range extra {
+		args = append(args, e)
+	}
+	keys := []string{key, lockCmd}
+	if c.useCompactReply(ctx, lockCmd) {
+		keys = append(keys, "int")
+	}
+	// KEYS[4]：加写锁成功时也发布通知，KEYS[5]：审计stream的最大长度
+	if c.conf().publishAcquire || c.conf().auditMaxLen > 0 {
+		if len(keys) == 2 {
+			keys = append(keys, "")
+		}
+		pub := ""
+		if c.conf().publishAcquire {
+			pub = "pub"
+		}
+		keys = append(keys, pub)
+		if c.conf().auditMaxLen > 0 {
+			keys = append(keys, strconv.FormatInt(c.conf().auditMaxLen, 10))
+		}
+	}
 	var ret interface{}
 	var err error
-	switch lockCmd {
-	case LockCmd:
-		ret, err = Redis.EvalSha(context.Background(), shaHashID, []string{key, lockCmd}, []string{uniqID, strconv.Itoa(int(expireTime))}).Result()
-	case UnlockCmd:
-		ret, err = Redis.EvalSha(context.Background(), shaHashID, []string{key, lockCmd}, []string{uniqID}).Result()
-	case RLockCmd, RUnlockCmd:
-		ret, err = Redis.EvalSha(context.Background(), shaHashID, []string{key, lockCmd}, []string{uniqID}).Result()
+	if c.conf().evalSha != nil {
+		ret, err = c.conf().evalSha(ctx, sha, keys, args...)
+	} else {
+		ret, err = rdb.EvalSha(ctx, sha, keys, args...).Result()
 	}
-
 	if err != nil {
 		return nil, err
 	}
-	var retJson = ret.(string)
+	// 整数回馈，只有成功和占用两种状态
+	if n, ok := ret.(int64); ok {
+		if res, ok := compactReply(n); ok {
+			return res, nil
+		}
+	}
+	// 脚本的返回值无法解析时重试也没有意义，返回 ErrMalformedReply 让调用方立即失败
+	retJson, ok := ret.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %#v", ErrMalformedReply, ret)
+	}
 	var res responseLock
 	if err := json.Unmarshal([]byte(retJson), &res); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v, reply=%q", ErrMalformedReply, err, retJson)
 	}
 	return &res, nil
 }
 
+// 脚本返回的错误信息和对应的错误
+var replyErrors = map[string]error{
+	"upgrade deadlock":           ErrUpgradeDeadlock,
+	"lease shorter than min ttl": ErrLeaseTooShort,
+	"held by forbidden owner":    ErrForbiddenOwner,
+	"shorten would extend":       ErrWouldExtend,
+}
+
+// replyError
+// 把脚本返回的错误信息转换成error，已知的错误返回对应的错误变量
+func replyError(res *responseLock) error {
+	if err, ok := replyErrors[res.Error()]; ok {
+		return err
+	}
+	return errors.New(res.Error())
+}
+
+// sendOnce
+// 只发送一次指令，不重试
+// 返回脚本是否执行成功，锁被占用时返回false和nil，锁在冷却期内时返回false和 ErrInCooldown
+func (c *Client) sendOnce(ctx context.Context, key, uniqID, lockCmd string, expireTime int64, extra ...string) (bool, error) {
+	if len(key) <= 0 {
+		return false, errors.New("lock key is nil")
+	}
+	if isAcquireCmd(lockCmd) && c.Draining() {
+		return false, ErrDraining
+	}
+	if c.conf().maxHeld > 0 && c.held.exceeds(lockCmd, key, uniqID, c.conf().maxHeld) {
+		return false, ErrTooManyLocks
+	}
+	res, err := c.sendLock(ctx, key, uniqID, lockCmd, expireTime, extra...)
+	if err != nil {
+		c.handleErrorContext(ctx, err)
+		return false, err
+	}
+	if res.IsError() {
+		return false, replyError(res)
+	}
+	if !res.Success() && res.Cooldown > 0 {
+		return false, fmt.Errorf("%w: %s remaining", ErrInCooldown, time.Duration(res.Cooldown)*time.Millisecond)
+	}
+	if res.Success() {
+		c.held.track(lockCmd, key, uniqID)
+	}
+	return res.Success(), nil
+}
+
 // handleError
 // 统一处理错误信息
-func handleError(err error) bool {
+func (c *Client) handleError(err error) bool {
+	return c.handleErrorContext(context.Background(), err)
+}
+
+// handleErrorContext
+// 同 handleError，重新加载脚本时使用调用方的ctx，不会超过调用方的deadline
+// 重连是多个协程共享的，不受单个调用方的ctx影响
+func (c *Client) handleErrorContext(ctx context.Context, err error) bool {
 	if err == nil {
 		return false
 	}
+	if isClusterRedirect(err) {
+		// 集群迁移中的重定向，ClusterClient会自己跟随重定向
+		// 漏出来的按普通可重试错误处理，不能触发重新初始化
+		return true
+	}
 	switch err.Error() {
 	case EofError:
 		// 收到了Eof，redis服务重启
-		if err := handleEofError(); err != nil {
+		// 关闭了自动重连时交给调用方处理
+		if !c.conf().autoReinit {
+			return false
+		}
+		if err := c.handleEofError(); err != nil {
 			return false
 		}
 		return true
 	case NoScriptError:
 		// redis没有找到对应的Lua脚本
-		if err := handleNoScriptError(); err != nil {
+		if err := c.handleNoScriptError(ctx); err != nil {
 			return false
 		}
 		return true
@@ -233,14 +689,84 @@ func handleError(err error) bool {
 
 }
 
+// isClusterRedirect
+// 是否是集群的MOVED/ASK/TRYAGAIN/CLUSTERDOWN错误
+func isClusterRedirect(err error) bool {
+	msg := err.Error()
+	for _, prefix := range clusterRetryPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 重连退避的最大间隔
+const maxReconnectInterval = 30 * time.Second
+
 // redis重启
 // 重试初始化一次
-func handleEofError() error {
-	return DoInit(opts)
+// 两次重连之间至少间隔 WithReconnectInterval（连续失败时翻倍，最多30秒），
+// 间隔内以及正在重连时，其他协程直接共享最近一次重连的结果，避免大量协程同时重连冲垮redis
+func (c *Client) handleEofError() error {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+
+	interval := c.conf().reconnectInterval << c.reconnectFailures
+	if interval <= 0 || interval > maxReconnectInterval {
+		interval = maxReconnectInterval
+	}
+	if !c.lastReconnect.IsZero() && time.Since(c.lastReconnect) < interval {
+		return c.lastReconnectErr
+	}
+
+	c.conf().metrics.IncReconnect()
+	c.lastReconnect = time.Now()
+	c.lastReconnectErr = c.connect(c.opts)
+	if c.lastReconnectErr != nil {
+		if c.reconnectFailures < 16 {
+			c.reconnectFailures++
+		}
+	} else {
+		c.reconnectFailures = 0
+	}
+	return c.lastReconnectErr
+}
+
+// Reconnect
+// 使用初始化时的配置重新创建redis客户端并加载Lua脚本
+// 关闭 WithAutoReinit 后，调用方收到EOF时可以自行调用
+func Reconnect() error {
+	return std.Reconnect()
+}
+
+// Reconnect
+// 同 Reconnect
+func (c *Client) Reconnect() error {
+	if c.opts == nil {
+		return ErrNotInitialized
+	}
+	return c.connect(c.opts)
 }
 
 // Lua script 不存在
 // 重新Load一下Lua
-func handleNoScriptError() error {
-	return LoadLua()
+// 同一时间只有一个协程重新加载，排在后面的协程先检查脚本是否已经被加载，
+// 一次重连或者SCRIPT FLUSH之后大量协程同时收到NOSCRIPT时只加载一次
+func (c *Client) handleNoScriptError(ctx context.Context) error {
+	if c.conn() == nil {
+		return ErrNotInitialized
+	}
+	c.scriptReloadMu.Lock()
+	defer c.scriptReloadMu.Unlock()
+	if ret, err := c.conn().ScriptExists(ctx, scriptSHA1()).Result(); err == nil && len(ret) > 0 && ret[0] {
+		return nil
+	}
+	c.conf().metrics.IncScriptReload()
+	// 集群扩容后新加入的master还没有脚本，触发集群拓扑的刷新（异步）
+	// 本次加载如果还没覆盖到返回NOSCRIPT的节点，重试时再次NOSCRIPT会用新的拓扑加载
+	if cluster, ok := c.conn().(*redis.ClusterClient); ok {
+		cluster.ReloadState(ctx)
+	}
+	return c.loadLua(ctx)
 }
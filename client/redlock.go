@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Redlock的时钟漂移系数，有效期要扣除过期时间乘以这个系数再加2毫秒
+const RedlockDriftFactor = 0.01
+
+// Redlock
+// 在N个相互独立的redis上加锁，超过半数加锁成功才算持有，任意少数节点故障时锁仍然有效
+// 每个节点上使用的是单节点的写锁（同 TryLock），节点之间没有复制关系
+// 注意Redlock的安全性依赖各节点的时钟速率大致一致，持有者在有效期（validity）结束前必须完成临界区
+type Redlock struct {
+	clients []*Client
+	quorum  int
+}
+
+// NewRedlock
+// 为每个optObj（同 DoInit）创建一个独立的客户端，options作用在所有客户端上
+// 任意一个节点连接失败时关闭已经创建的客户端并返回错误；建议使用奇数个（至少3个）节点
+func NewRedlock(optObjs []interface{}, options ...Option) (*Redlock, error) {
+	if len(optObjs) == 0 {
+		return nil, errors.New("redlock needs at least one redis")
+	}
+	r := &Redlock{quorum: len(optObjs)/2 + 1}
+	for i, opt := range optObjs {
+		c, err := NewClient(opt, options...)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("redlock instance %d: %w", i, err)
+		}
+		r.clients = append(r.clients, c)
+	}
+	return r, nil
+}
+
+// Quorum
+// 需要加锁成功的节点数
+func (r *Redlock) Quorum() int {
+	return r.quorum
+}
+
+// TryLock
+// 在所有节点上各尝试一次写锁，过期时间为expireTime秒
+// 超过半数成功、并且扣除加锁耗时和时钟漂移后还有剩余时间时返回有效期validity，否则释放已经拿到的节点并返回 ErrNoQuorum
+// 单个节点的请求最多等待过期时间的1/10，避免一个挂掉的节点耗尽有效期
+func (r *Redlock) TryLock(key, uniqID string, expireTime int64) (validity time.Duration, err error) {
+	if expireTime <= 0 || expireTime > MaxExpire {
+		return 0, ErrInvalidExpire
+	}
+	ttl := time.Duration(expireTime) * time.Second
+	start := time.Now()
+	ok := r.each(func(c *Client) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), ttl/10)
+		defer cancel()
+		return c.LockWithPolicy(ctx, key, uniqID, expireTime, WaitPolicy{MaxAttempts: 1}) == nil
+	})
+	drift := time.Duration(float64(ttl)*RedlockDriftFactor) + 2*time.Millisecond
+	validity = ttl - time.Since(start) - drift
+	if ok >= r.quorum && validity > 0 {
+		return validity, nil
+	}
+	// 没有达到多数或者已经没有有效期，释放所有节点，包括请求超时、不知道结果的节点
+	r.Unlock(key, uniqID)
+	return 0, fmt.Errorf("%w: %d/%d instances locked", ErrNoQuorum, ok, len(r.clients))
+}
+
+// Lock
+// 重复 TryLock 直到成功，两次尝试之间随机睡眠（同单节点的重试间隔）
+// ctx取消时返回ctx.Err()
+func (r *Redlock) Lock(ctx context.Context, key, uniqID string, expireTime int64) (validity time.Duration, err error) {
+	for {
+		validity, err = r.TryLock(key, uniqID, expireTime)
+		if !errors.Is(err, ErrNoQuorum) {
+			return validity, err
+		}
+		timer := time.NewTimer(r.clients[0].getRandomSleepTime())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Unlock
+// 在所有节点上释放写锁，脚本只会删除自己持有的锁
+// 少于半数的节点释放成功时返回 ErrNoQuorum，其余节点上的锁等过期后释放
+func (r *Redlock) Unlock(key, uniqID string) error {
+	ok := r.each(func(c *Client) bool {
+		err, _ := c.unlock(context.Background(), key, uniqID)
+		return err == nil
+	})
+	if ok < r.quorum {
+		return fmt.Errorf("%w: %d/%d instances unlocked", ErrNoQuorum, ok, len(r.clients))
+	}
+	return nil
+}
+
+// Close
+// 关闭所有节点的客户端
+func (r *Redlock) Close() error {
+	var first error
+	for _, c := range r.clients {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// each
+// 在所有节点上并发执行fn，返回成功的节点数
+func (r *Redlock) each(fn func(c *Client) bool) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := 0
+	for _, c := range r.clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			if fn(c) {
+				mu.Lock()
+				ok++
+				mu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+	return ok
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LockWithRenew
+// 加写锁并启动后台续期，每隔过期时间的1/3续期一次
+// 返回的cancel和ctx取消都会停止续期，cancel可以重复调用，返回时续期协程已经退出
+// 停止续期不会释放锁，仍然需要调用 Unlock
+// 续期发现锁已经不属于自己时停止续期，并调用 WithOnRenewFailed 设置的回调
+// 每次续期成功后调用 WithOnRenewed 设置的回调
+func LockWithRenew(ctx context.Context, key, uniqID string, expireTime int64) (cancel func(), err error) {
+	return std.LockWithRenew(ctx, key, uniqID, expireTime)
+}
+
+// LockWithRenew
+// 同 LockWithRenew
+func (c *Client) LockWithRenew(ctx context.Context, key, uniqID string, expireTime int64) (cancel func(), err error) {
+	expireTime, err = c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.acquire(ctx, key, uniqID, LockCmd, expireTime); err != nil {
+		return nil, err
+	}
+	// 永不过期的锁不需要续期
+	if expireTime == NoExpire {
+		return func() {}, nil
+	}
+	return c.startRenew(ctx, key, uniqID, expireTime), nil
+}
+
+// startRenew
+// 启动续期协程，返回停止函数
+func (c *Client) startRenew(ctx context.Context, key, uniqID string, expireTime int64) func() {
+	ctx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	r := &renewal{stop: stop, done: done}
+	c.renewals.add(key, uniqID, r)
+	go func() {
+		defer close(done)
+		defer c.renewals.remove(key, uniqID, r)
+		c.renewLoop(ctx, key, uniqID, expireTime, r)
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(stop)
+		<-done
+	}
+}
+
+// renewLoop
+// 定时续期，直到ctx取消或者锁丢失
+func (c *Client) renewLoop(ctx context.Context, key, uniqID string, expireTime int64, r *renewal) {
+	interval := time.Duration(expireTime) * time.Second / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 正在交接，交接失败时下一次再续期
+		if atomic.LoadInt32(&r.paused) == 1 {
+			continue
+		}
+		ok, err := c.renewOnce(ctx, key, uniqID, expireTime)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// 网络抖动时等下一次续期，锁真的过期后下一次续期会返回false
+			logWith(c.conf().logger, Fields{FieldKey: key, FieldOp: ExtendCmd, FieldToken: uniqID}).Printf("renew lock %s failed: %v", key, err)
+			continue
+		}
+		if !ok {
+			// 锁已经不属于自己，不再计入持有的锁
+			c.held.removeAll(key, uniqID, true)
+			if c.conf().onRenewFailed != nil {
+				c.conf().onRenewFailed(key, uniqID, ErrLockLost)
+			}
+			return
+		}
+		if c.conf().onRenewed != nil {
+			c.conf().onRenewed(key, uniqID, time.Duration(expireTime)*time.Second)
+		}
+	}
+}
+
+// renewOnce
+// 续期一次，出错时按 WithRenewTolerance 在短时间内重试，只有脚本明确回馈锁不属于自己时才返回false
+func (c *Client) renewOnce(ctx context.Context, key, uniqID string, expireTime int64) (bool, error) {
+	ok, err := c.sendOnce(ctx, key, uniqID, ExtendCmd, expireTime)
+	tol := c.conf().renewTolerance
+	if err == nil || tol.retries <= 0 {
+		return ok, err
+	}
+	gap := tol.window / time.Duration(tol.retries)
+	for i := 0; i < tol.retries && err != nil; i++ {
+		timer := time.NewTimer(gap)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+		ok, err = c.sendOnce(ctx, key, uniqID, ExtendCmd, expireTime)
+	}
+	return ok, err
+}
+
+// renewal
+// 一个后台续期协程
+type renewal struct {
+	stop func()
+	done chan struct{}
+	// 为1时跳过续期，用于交接期间
+	paused int32
+}
+
+// renewRegistry
+// 本客户端正在续期的写锁，交接时用于找到并停止旧持有者的续期
+type renewRegistry struct {
+	mu    sync.Mutex
+	locks map[heldKey]*renewal
+}
+
+// newRenewRegistry
+// 创建空的记录
+func newRenewRegistry() *renewRegistry {
+	return &renewRegistry{locks: make(map[heldKey]*renewal)}
+}
+
+func (r *renewRegistry) add(key, uniqID string, rn *renewal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locks[heldKey{key, uniqID, true}] = rn
+}
+
+// remove
+// 续期协程退出时删除，同一把锁之后又启动了新的续期时不删除
+func (r *renewRegistry) remove(key, uniqID string, rn *renewal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := heldKey{key, uniqID, true}
+	if r.locks[k] == rn {
+		delete(r.locks, k)
+	}
+}
+
+func (r *renewRegistry) get(key, uniqID string) *renewal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.locks[heldKey{key, uniqID, true}]
+}
@@ -0,0 +1,176 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+)
+
+// extendScript
+// 加锁总是成功，EXTEND按顺序返回replies，用完后一直成功
+type extendScript struct {
+	mu      sync.Mutex
+	replies []fakeReply
+	extends int
+}
+
+func (s *extendScript) evalSha(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+	if keys[1] != client.ExtendCmd {
+		return okReply, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extends++
+	if len(s.replies) == 0 {
+		return okReply, nil
+	}
+	r := s.replies[0]
+	s.replies = s.replies[1:]
+	return r.ret, r.err
+}
+
+func (s *extendScript) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.extends
+}
+
+// renewEvents
+// 记录续期的回调
+type renewEvents struct {
+	failed  chan error
+	renewed chan struct{}
+}
+
+func newRenewClient(t *testing.T, s *extendScript, options ...client.Option) (*client.Client, *renewEvents) {
+	t.Helper()
+	ev := &renewEvents{failed: make(chan error, 10), renewed: make(chan struct{}, 10)}
+	options = append(options,
+		client.WithOnRenewFailed(func(key, uniqID string, err error) { ev.failed <- err }),
+		client.WithOnRenewed(func(key, uniqID string, ttl time.Duration) { ev.renewed <- struct{}{} }),
+		client.WithLogger(nopLogger{}))
+	c, err := client.NewClientWithEvalSha(s.evalSha, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, ev
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+var errBlip = errors.New("dial tcp: i/o timeout")
+
+// 网络抖动在容忍窗口内重试成功，不算丢锁
+func TestRenewToleratesTransientErrors(t *testing.T) {
+	s := &extendScript{replies: []fakeReply{{err: errBlip}, {err: errBlip}}}
+	c, ev := newRenewClient(t, s, client.WithRenewTolerance(2, 100*time.Millisecond))
+	cancel, err := c.LockWithRenew(context.Background(), "renew:1", "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	select {
+	case <-ev.renewed:
+	case err := <-ev.failed:
+		t.Fatalf("OnRenewFailed(%v) on a transient error", err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the lock was never renewed")
+	}
+	if n := s.count(); n != 3 {
+		t.Fatalf("EXTEND calls = %d; want 3 (two blips, then success)", n)
+	}
+	if !c.HoldsWrite("renew:1") {
+		t.Fatal("the lock was dropped from HeldLocks")
+	}
+}
+
+// 容忍的次数用完也只是等下一个续期周期，不调用 OnRenewFailed
+func TestRenewErrorsBeyondToleranceAreNotLoss(t *testing.T) {
+	s := &extendScript{replies: []fakeReply{{err: errBlip}, {err: errBlip}, {err: errBlip}}}
+	c, ev := newRenewClient(t, s, client.WithRenewTolerance(1, 50*time.Millisecond))
+	cancel, err := c.LockWithRenew(context.Background(), "renew:1", "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	select {
+	case <-ev.renewed:
+	case err := <-ev.failed:
+		t.Fatalf("OnRenewFailed(%v) on transient errors", err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("the lock was never renewed")
+	}
+	if n := s.count(); n != 4 {
+		t.Fatalf("EXTEND calls = %d; want 4 (two per period, success in the second)", n)
+	}
+}
+
+// 脚本明确回馈锁属于别人时立即判定丢锁，不再重试
+func TestRenewDetectsSteal(t *testing.T) {
+	s := &extendScript{replies: []fakeReply{{ret: `{"opRet":false,"status":"busy","owner":"thief"}`}}}
+	c, ev := newRenewClient(t, s, client.WithRenewTolerance(3, 100*time.Millisecond))
+	cancel, err := c.LockWithRenew(context.Background(), "renew:1", "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	select {
+	case err := <-ev.failed:
+		if err != client.ErrLockLost {
+			t.Fatalf("OnRenewFailed(%v); want ErrLockLost", err)
+		}
+	case <-ev.renewed:
+		t.Fatal("renewed a stolen lock")
+	case <-time.After(3 * time.Second):
+		t.Fatal("the steal was never detected")
+	}
+	cancel()
+	if n := s.count(); n != 1 {
+		t.Fatalf("EXTEND calls = %d; want 1", n)
+	}
+	if c.HoldsWrite("renew:1") {
+		t.Fatal("a stolen lock is still in HeldLocks")
+	}
+}
+
+// 取消传给 LockWithRenew 的ctx后续期协程退出，不再续期
+// 不能和其他测试并行，否则协程数量受其他测试影响
+func TestRenewStopsOnContextCancel(t *testing.T) {
+	s := &extendScript{}
+	c, _ := newRenewClient(t, s)
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop, err := c.LockWithRenew(ctx, "renew:1", "a", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if n := runtime.NumGoroutine(); n <= before {
+		t.Fatalf("goroutines = %d after LockWithRenew; want more than %d", n, before)
+	}
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines = %d one second after cancel; want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 续期间隔为1秒，协程退出后不会再续期
+	time.Sleep(1200 * time.Millisecond)
+	if n := s.count(); n != 0 {
+		t.Fatalf("EXTEND calls = %d after cancel; want 0", n)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const fullReply = `{"opRet":false,"status":"busy","owner":"a","ttl":3000,"readers":2,"waiters":1,"position":1,` +
+	`"meta":"job","readerList":[{"id":"r1","ttl":900}],"fence":7,"cooldown":0,"more":true,"cleaned":3}`
+
+// replyClient
+// 脚本每次返回reply的客户端
+func replyClient(t *testing.T, reply *interface{}, calls *int) *Client {
+	c, err := NewClientWithEvalSha(func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		if keys[1] == LockCmd {
+			*calls++
+		}
+		return *reply, nil
+	}, WithLogger(nopLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// 截断的回馈都不是合法的JSON，必须立即返回 ErrMalformedReply 并带上原始回馈，不能重试
+func TestTruncatedReplies(t *testing.T) {
+	var reply interface{}
+	calls := 0
+	c := replyClient(t, &reply, &calls)
+	for i := 0; i < len(fullReply); i++ {
+		reply = fullReply[:i]
+		calls = 0
+		_, err := c.acquireReply(context.Background(), "order:1", "a", LockCmd, 5, AcquirePolicy{})
+		if !errors.Is(err, ErrMalformedReply) {
+			t.Fatalf("reply %q: err = %v; want ErrMalformedReply", reply, err)
+		}
+		if calls != 1 {
+			t.Fatalf("reply %q: %d attempts; want 1", reply, calls)
+		}
+	}
+}
+
+// 随机改写回馈中的字节：解析要么成功，要么返回 ErrMalformedReply，不能panic
+func TestMutatedReplies(t *testing.T) {
+	var reply interface{}
+	calls := 0
+	c := replyClient(t, &reply, &calls)
+	r := rand.New(rand.NewSource(1))
+	const alphabet = `{}[]",:0123456789-.eEtrufalsn \` + "\x00\xff"
+	for i := 0; i < 5000; i++ {
+		b := []byte(fullReply)
+		for n := r.Intn(4) + 1; n > 0; n-- {
+			b[r.Intn(len(b))] = alphabet[r.Intn(len(alphabet))]
+		}
+		reply = string(b)
+		res, err := c.sendLock(context.Background(), "order:1", "a", LockCmd, 5)
+		if err != nil {
+			if !errors.Is(err, ErrMalformedReply) {
+				t.Fatalf("reply %q: err = %v; want ErrMalformedReply", reply, err)
+			}
+			if !strings.Contains(err.Error(), "reply=") {
+				t.Fatalf("reply %q: err = %v; want the raw reply in it", reply, err)
+			}
+			continue
+		}
+		_ = res.State()
+		_ = res.Error()
+	}
+}
+
+// 脚本返回JSON以外的类型
+func TestNonStringReplies(t *testing.T) {
+	var reply interface{}
+	calls := 0
+	c := replyClient(t, &reply, &calls)
+	for _, ret := range []interface{}{nil, int64(2), int64(-1), []interface{}{"x"}, []byte("{}"), 1.5} {
+		reply = ret
+		if _, err := c.sendLock(context.Background(), "order:1", "a", LockCmd, 5); !errors.Is(err, ErrMalformedReply) {
+			t.Errorf("reply %#v: err = %v; want ErrMalformedReply", ret, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+
+	"github.com/lzw5399/rwlock/lua"
+)
+
+// ScriptSHA
+// 当前加载到redis的脚本hash，未初始化时为空
+func ScriptSHA() string {
+	return std.ScriptSHA()
+}
+
+// ScriptSHA
+// 同 ScriptSHA
+func (c *Client) ScriptSHA() string {
+	return c.sha()
+}
+
+// ScriptBody
+// 内嵌的脚本源码，可以和redis中的脚本比对，确认运行的是哪个版本
+func ScriptBody() string {
+	return std.ScriptBody()
+}
+
+// ScriptBody
+// 同 ScriptBody
+func (c *Client) ScriptBody() string {
+	return lua.ScriptContent
+}
+
+// VerifyScript
+// 校验 ScriptSHA 是内嵌脚本的SHA1，并且通过SCRIPT EXISTS确认redis中确实有这个脚本
+// 返回false说明hash被修改过，或者脚本缓存被清空（例如SCRIPT FLUSH、redis重启），下一次加锁会重新加载
+// 集群模式下要求所有master节点都有这个脚本
+func VerifyScript(ctx context.Context) (bool, error) {
+	return std.VerifyScript(ctx)
+}
+
+// VerifyScript
+// 同 VerifyScript
+func (c *Client) VerifyScript(ctx context.Context) (bool, error) {
+	if c.conn() == nil {
+		return false, ErrNotInitialized
+	}
+	sha := c.sha()
+	if len(sha) <= 0 || sha != scriptSHA1() {
+		return false, nil
+	}
+	exists, err := c.conn().ScriptExists(ctx, sha).Result()
+	if err != nil {
+		return false, err
+	}
+	return len(exists) > 0 && exists[0], nil
+}
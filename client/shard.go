@@ -0,0 +1,38 @@
+package client
+
+import "hash/crc32"
+
+// Sharder
+// 计算key落在第几个分片上，返回值需要在[0, n)之间
+// 同一个key在不同进程、重启前后都必须得到相同的结果
+type Sharder func(key string, n int) int
+
+// CRC32Sharder
+// 默认的分片函数，使用CRC32(IEEE)取模
+func CRC32Sharder(key string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+	return int(crc32.ChecksumIEEE([]byte(key)) % uint32(n))
+}
+
+// ShardOf
+// 返回key所在的分片下标
+func ShardOf(key string) int {
+	return std.ShardOf(key)
+}
+
+// ShardOf
+// 同 ShardOf
+func (c *Client) ShardOf(key string) int {
+	n := c.conf().shardCount
+	if n <= 1 {
+		return 0
+	}
+	idx := c.conf().sharder(key, n)
+	// 自定义的分片函数越界时兜底，避免调用方数组越界
+	if idx < 0 || idx >= n {
+		return CRC32Sharder(key, n)
+	}
+	return idx
+}
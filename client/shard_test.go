@@ -0,0 +1,88 @@
+package client_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/lzw5399/rwlock/client"
+)
+
+// 不连接redis的客户端，只用来测试不发送指令的功能
+func offlineClient(t *testing.T, options ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.NewClientWithEvalSha(func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		t.Fatal("unexpected redis call")
+		return nil, nil
+	}, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// 分片结果必须跨进程、跨版本稳定，这些值来自CRC32(IEEE)
+func TestCRC32SharderKnownVectors(t *testing.T) {
+	tests := []struct {
+		key  string
+		n    int
+		want int
+	}{
+		{"123456789", 8, 6},
+		{"123456789", 3, 2},
+		{"order:1", 8, 1},
+		{"order:1", 16, 9},
+		{"user:42", 3, 1},
+		{"", 8, 0},
+		{"order:1", 1, 0},
+		{"order:1", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := client.CRC32Sharder(tt.key, tt.n); got != tt.want {
+			t.Errorf("CRC32Sharder(%q, %d) = %d; want %d", tt.key, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestShardOfDistribution(t *testing.T) {
+	const shards, keys = 16, 16000
+	c := offlineClient(t, client.WithShardCount(shards))
+	counts := make([]int, shards)
+	for i := 0; i < keys; i++ {
+		key := "order:" + strconv.Itoa(i)
+		idx := c.ShardOf(key)
+		if idx != c.ShardOf(key) {
+			t.Fatalf("ShardOf(%q) is not stable", key)
+		}
+		counts[idx]++
+	}
+	// 每个分片的数量在平均值的±15%以内
+	mean := keys / shards
+	for i, n := range counts {
+		if n < mean*85/100 || n > mean*115/100 {
+			t.Errorf("shard %d has %d keys; want about %d (all: %v)", i, n, mean, counts)
+		}
+	}
+}
+
+func TestWithSharder(t *testing.T) {
+	c := offlineClient(t, client.WithShardCount(4), client.WithSharder(func(key string, n int) int {
+		return len(key) % n
+	}))
+	if got := c.ShardOf("abcde"); got != 1 {
+		t.Fatalf("ShardOf with custom sharder = %d; want 1", got)
+	}
+
+	// 越界时退回CRC32
+	bad := offlineClient(t, client.WithShardCount(4), client.WithSharder(func(key string, n int) int {
+		return n + 1
+	}))
+	if got, want := bad.ShardOf("order:1"), client.CRC32Sharder("order:1", 4); got != want {
+		t.Fatalf("ShardOf with an out-of-range sharder = %d; want the CRC32 fallback %d", got, want)
+	}
+
+	// 没有分片时总是0
+	if got := offlineClient(t).ShardOf("order:1"); got != 0 {
+		t.Fatalf("ShardOf without shards = %d; want 0", got)
+	}
+}
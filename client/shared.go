@@ -0,0 +1,10 @@
+package client
+
+// readCmds
+// 返回读锁的加锁、释放、续期指令，开启 WithSharedReadTTL 时使用共享过期时间的指令
+func (c *Client) readCmds() (rlockCmd, runlockCmd, rextendCmd string) {
+	if c.conf().sharedReadTTL {
+		return SRLockCmd, SRUnlockCmd, SRExtendCmd
+	}
+	return RLockCmd, RUnlockCmd, RExtendCmd
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LockStatus
+// 锁的当前状态
+type LockStatus struct {
+	Key string
+	// 写锁的持有者，为空表示没有写锁
+	Owner string
+	// 写锁的剩余时间，-1表示永不过期
+	TTL time.Duration
+	// 当前的读者数量（匿名读锁+未过期的读者）
+	Readers int
+	// 写锁持有者加锁时附带的元数据
+	Metadata string
+	// 写锁持有者加锁时通过 WithTraceID 附带的trace ID
+	TraceID string
+	// 未过期的读者及剩余时间，按剩余时间从短到长排列，最多列出100个
+	// 匿名读锁（RLock 或 RLockID 不带uniqID）没有ID，只计入 Readers 不会列出
+	ReaderList []ReaderInfo
+}
+
+// ReaderInfo
+// 一个读者的信息
+type ReaderInfo struct {
+	ID           string
+	RemainingTTL time.Duration
+}
+
+// String
+// 格式如 `order:1: write-locked by abc123 (ttl 3.2s), 2 readers, meta "job 12345"`
+func (s LockStatus) String() string {
+	var parts []string
+	if len(s.Owner) > 0 {
+		parts = append(parts, fmt.Sprintf("write-locked by %s (%s)", s.Owner, formatTTL(s.TTL)))
+	}
+	switch {
+	case s.Readers == 1:
+		parts = append(parts, "1 reader")
+	case s.Readers > 1:
+		parts = append(parts, fmt.Sprintf("%d readers", s.Readers))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "free")
+	}
+	if len(s.Metadata) > 0 {
+		parts = append(parts, fmt.Sprintf("meta %q", s.Metadata))
+	}
+	if len(s.TraceID) > 0 {
+		parts = append(parts, "trace "+s.TraceID)
+	}
+	str := strings.Join(parts, ", ")
+	if len(s.Key) > 0 {
+		str = s.Key + ": " + str
+	}
+	return str
+}
+
+// String
+// 格式如 "abc123 (ttl 3.2s)"
+func (r ReaderInfo) String() string {
+	return fmt.Sprintf("%s (%s)", r.ID, formatTTL(r.RemainingTTL))
+}
+
+// formatTTL
+// 剩余时间保留到100毫秒，-1表示永不过期
+func formatTTL(ttl time.Duration) string {
+	if ttl < 0 {
+		return "no expire"
+	}
+	return "ttl " + ttl.Round(100*time.Millisecond).String()
+}
+
+// Status
+// 查询锁的状态
+func Status(ctx context.Context, key string) (LockStatus, error) {
+	return std.Status(ctx, key)
+}
+
+// Status
+// 同 Status
+func (c *Client) Status(ctx context.Context, key string) (LockStatus, error) {
+	st, _, err := c.status(ctx, key)
+	return st, err
+}
+
+// status
+// 查询锁的状态，同时返回redis中脚本的版本号，避免再多一次请求
+func (c *Client) status(ctx context.Context, key string) (LockStatus, string, error) {
+	st := LockStatus{Key: key}
+	res, err := c.sendLock(ctx, key, "", StatusCmd, 0)
+	if err != nil {
+		c.handleError(err)
+		return st, "", err
+	}
+	if res.IsError() {
+		return st, res.Version, replyError(res)
+	}
+	st.Owner = res.Owner
+	st.Metadata = res.Meta
+	st.TraceID = res.Trace
+	st.Readers = res.Readers
+	for _, r := range res.ReaderList {
+		st.ReaderList = append(st.ReaderList, ReaderInfo{ID: r.ID, RemainingTTL: time.Duration(r.TTL) * time.Millisecond})
+	}
+	// 普通读者和按权重的读者分开存放，合并后重新排序
+	sort.Slice(st.ReaderList, func(i, j int) bool {
+		return st.ReaderList[i].RemainingTTL < st.ReaderList[j].RemainingTTL
+	})
+	switch {
+	case len(res.Owner) <= 0:
+	case res.TTL < 0:
+		st.TTL = -1
+	default:
+		st.TTL = time.Duration(res.TTL) * time.Millisecond
+	}
+	return st, res.Version, nil
+}
+
+// ReaderCount
+// 查询当前的读者数量
+func ReaderCount(key string) (int, error) {
+	return std.ReaderCount(key)
+}
+
+// ReaderCount
+// 同 ReaderCount
+func (c *Client) ReaderCount(key string) (int, error) {
+	st, err := c.Status(context.Background(), key)
+	return st.Readers, err
+}
+
+// WaiterCount
+// 查询排队等待写锁的数量
+// 已经崩溃的等待者心跳过期后会被清理，不计入数量
+func WaiterCount(key string) (int, error) {
+	return std.WaiterCount(key)
+}
+
+// WaiterCount
+// 同 WaiterCount
+func (c *Client) WaiterCount(key string) (int, error) {
+	res, err := c.sendLock(context.Background(), key, "", WaitersCmd, 0)
+	if err != nil {
+		c.handleError(err)
+		return 0, err
+	}
+	if res.IsError() {
+		return 0, replyError(res)
+	}
+	return res.Waiters, nil
+}
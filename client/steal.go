@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// Takeover
+// LockOrSteal 的结果
+type Takeover struct {
+	// 是否接管了上一个持有者过期未释放的锁
+	Stolen bool
+	// 上一个持有者的uniqID和加锁时附带的元数据（见 LockWithMeta），用于排查它为什么没有释放
+	PrevOwner    string
+	PrevMetadata string
+}
+
+// String
+// 格式如 `stolen from job-789 (meta "backup")`
+func (t Takeover) String() string {
+	if !t.Stolen {
+		return "not stolen"
+	}
+	if len(t.PrevMetadata) > 0 {
+		return fmt.Sprintf("stolen from %s (meta %q)", t.PrevOwner, t.PrevMetadata)
+	}
+	return "stolen from " + t.PrevOwner
+}
+
+// LockOrSteal
+// 加写锁，等待规则同 LockUntil，锁的过期时间为expireTime
+// 上一个持有者没有 Unlock、锁是过期后才空出来的（通常是进程崩溃），返回的 Takeover.Stolen 为true，
+// 并带上它的uniqID和元数据，可以记录类似 "took over lock previously held by job-789" 的日志
+// 锁过期超过一小时后不再保留上一个持有者的记录
+func LockOrSteal(ctx context.Context, key, uniqID string, expireTime int64) (Takeover, error) {
+	return std.LockOrSteal(ctx, key, uniqID, expireTime)
+}
+
+// LockOrSteal
+// 同 LockOrSteal
+func (c *Client) LockOrSteal(ctx context.Context, key, uniqID string, expireTime int64) (Takeover, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return Takeover{}, err
+	}
+	res, err := c.acquireReply(withFullReply(ctx), key, uniqID, LockCmd, expireTime, AcquirePolicy{})
+	if err != nil {
+		return Takeover{}, err
+	}
+	return Takeover{
+		Stolen:       len(res.PrevOwner) > 0,
+		PrevOwner:    res.PrevOwner,
+		PrevMetadata: res.PrevMeta,
+	}, nil
+}
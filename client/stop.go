@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// LockWithStop
+// 写锁，stop关闭时放弃等待并返回 ErrCancelled
+// 给没有使用context的调用方使用，内部和ctx版本共用同一套等待逻辑
+func LockWithStop(stop <-chan struct{}, key, uniqID string, expireTime int64) error {
+	return std.LockWithStop(stop, key, uniqID, expireTime)
+}
+
+// LockWithStop
+// 同 LockWithStop
+func (c *Client) LockWithStop(stop <-chan struct{}, key, uniqID string, expireTime int64) error {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+	return stopError(c.acquire(ctx, key, uniqID, LockCmd, expireTime))
+}
+
+// RLockWithStop
+// 读锁，stop关闭时放弃等待并返回 ErrCancelled
+func RLockWithStop(stop <-chan struct{}, key, uniqID string) error {
+	return std.RLockWithStop(stop, key, uniqID)
+}
+
+// RLockWithStop
+// 同 RLockWithStop
+func (c *Client) RLockWithStop(stop <-chan struct{}, key, uniqID string) error {
+	ctx, cancel := stopContext(stop)
+	defer cancel()
+	rlockCmd, _, _ := c.readCmds()
+	return stopError(c.acquire(ctx, key, uniqID, rlockCmd, c.conf().readExpire))
+}
+
+// stopContext
+// 把stop channel转换成ctx，stop关闭时取消ctx
+func stopContext(stop <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// stopError
+// stopContext 只会因为stop被关闭而取消
+func stopError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return ErrCancelled
+	}
+	return err
+}
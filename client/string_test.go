@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockStatusString(t *testing.T) {
+	tests := []struct {
+		st   LockStatus
+		want string
+	}{
+		{LockStatus{}, "free"},
+		{LockStatus{Key: "order:1"}, "order:1: free"},
+		{LockStatus{Key: "order:1", Owner: "abc123", TTL: 3240 * time.Millisecond}, "order:1: write-locked by abc123 (ttl 3.2s)"},
+		{LockStatus{Key: "order:1", Owner: "abc123", TTL: -1}, "order:1: write-locked by abc123 (no expire)"},
+		{LockStatus{Key: "order:1", Readers: 1}, "order:1: 1 reader"},
+		{LockStatus{Key: "order:1", Readers: 2}, "order:1: 2 readers"},
+		{
+			LockStatus{Key: "order:1", Owner: "abc123", TTL: 3200 * time.Millisecond, Readers: 2, Metadata: "job 12345", TraceID: "t-1"},
+			`order:1: write-locked by abc123 (ttl 3.2s), 2 readers, meta "job 12345", trace t-1`,
+		},
+		{LockStatus{Metadata: "a\"b"}, `free, meta "a\"b"`},
+	}
+	for _, tt := range tests {
+		if got := tt.st.String(); got != tt.want {
+			t.Errorf("String() = %q; want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSummaryStrings(t *testing.T) {
+	tests := []struct {
+		s    interface{ String() string }
+		want string
+	}{
+		{ReaderInfo{ID: "abc123", RemainingTTL: 3249 * time.Millisecond}, "abc123 (ttl 3.2s)"},
+		{LockSummary{Type: LockTypeWrite, LockStatus: LockStatus{Key: "order:1", Owner: "abc123", TTL: 3200 * time.Millisecond}}, "[write] order:1: write-locked by abc123 (ttl 3.2s)"},
+		{Takeover{}, "not stolen"},
+		{Takeover{Stolen: true, PrevOwner: "job-789"}, "stolen from job-789"},
+		{Takeover{Stolen: true, PrevOwner: "job-789", PrevMetadata: "backup"}, `stolen from job-789 (meta "backup")`},
+		{CheckResult{Name: "ping", Latency: 1200 * time.Microsecond}, "ping ok (1.2ms)"},
+		{CheckResult{Name: "script", Err: errors.New("not loaded"), Latency: 800 * time.Microsecond}, "script failed: not loaded (800µs)"},
+		{NodeResult{Addr: "10.0.0.1:7000", ScriptLoaded: true, Latency: 1200 * time.Microsecond}, "10.0.0.1:7000 ok (1.2ms)"},
+		{NodeResult{Addr: "10.0.0.2:7000", Latency: 900 * time.Microsecond}, "10.0.0.2:7000 script missing (900µs)"},
+		{NodeResult{Addr: "10.0.0.3:7000", Err: errors.New("refused")}, "10.0.0.3:7000 unreachable: refused (0s)"},
+		{NodeResult{Addr: "10.0.0.4:7000", ScriptErr: errors.New("timeout")}, "10.0.0.4:7000 script check failed: timeout (0s)"},
+		{OwnerInfo{Host: "host-1", PID: 1234, Tag: "backup", Time: time.Date(2021, 1, 2, 15, 4, 5, 0, time.UTC)}, `host-1 pid 1234 "backup" since 2021-01-02T15:04:05Z`},
+		{responseLock{Status: StatusBusy, Owner: "a", Readers: 1, Waiters: 2}, `status=busy owner="a" readers=1 waiters=2 errMsg="" debug=""`},
+	}
+	for _, tt := range tests {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("%T.String() = %q; want %q", tt.s, got, tt.want)
+		}
+	}
+}
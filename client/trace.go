@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// startTrace
+// 为一次加锁开始一个runtime/trace的task，等待的过程是其中的region，`go tool trace`中可以看到协程等锁的时间
+// 没有在采集trace时什么都不做；返回的end在加锁结束时调用，记录结果
+func startTrace(ctx context.Context, key, lockCmd string) (context.Context, func(err error)) {
+	if !trace.IsEnabled() {
+		return ctx, func(error) {}
+	}
+	ctx, task := trace.NewTask(ctx, "rwlock.acquire")
+	trace.Log(ctx, "key", key)
+	trace.Log(ctx, "cmd", lockCmd)
+	region := trace.StartRegion(ctx, "rwlock.wait")
+	return ctx, func(err error) {
+		region.End()
+		if err != nil {
+			trace.Log(ctx, "error", err.Error())
+		}
+		task.End()
+	}
+}
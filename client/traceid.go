@@ -0,0 +1,32 @@
+package client
+
+import "context"
+
+// 锁上附带的trace ID的最大长度（字节）
+const MaxTraceIDSize = 128
+
+// ctx中存放trace ID的key
+type traceIDKey struct{}
+
+// WithTraceID
+// 返回带有trace ID的ctx，传给 LockUntil / Do / AcquireWith 等带ctx的写锁方法
+// 加锁成功时trace ID和持有者一起保存到redis中，其他服务通过 Status 可以看到锁被哪个调用链持有；释放锁时删除
+// 只对写锁生效，traceID为空时不保存，超过 MaxTraceIDSize 时加锁返回 ErrTraceIDTooLarge
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// traceIDFromContext
+// 取出 WithTraceID 放到ctx中的trace ID
+func traceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// withTraceArg
+// 写锁的ARGV[9]为trace ID，前面不足的参数补空
+func withTraceArg(extra []string, traceID string) []string {
+	args := make([]string, 6, 7)
+	copy(args, extra)
+	return append(args, traceID)
+}
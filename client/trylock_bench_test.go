@@ -0,0 +1,132 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+// countingClient
+// 连接miniredis的客户端，calls记录EvalSha的次数
+func countingClient(tb testing.TB) (c *client.Client, calls *int64) {
+	c, _, calls = countingServer(tb)
+	return c, calls
+}
+
+// countingServer
+// 同 countingClient，同时返回miniredis，用于直接准备redis中的数据
+func countingServer(tb testing.TB, options ...client.Option) (c *client.Client, m *miniredis.Miniredis, calls *int64) {
+	_, m, _ = rwlocktest.NewTestServer(tb)
+	rdb := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	tb.Cleanup(func() { rdb.Close() })
+	calls = new(int64)
+	evalSha := func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		atomic.AddInt64(calls, 1)
+		return rdb.EvalSha(ctx, sha, keys, args...).Result()
+	}
+	options = append([]client.Option{client.WithEvictionCheck(client.EvictionIgnore), client.WithEvalSha(evalSha)}, options...)
+	c, err := client.NewClient(&redis.Options{Addr: m.Addr()}, options...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { c.Close() })
+	return c, m, calls
+}
+
+// 只尝试一次的加锁失败时，离开等待队列和加锁在同一次脚本调用中完成，不会留在队列中挡住别人
+func TestSingleAttemptLockLeavesQueue(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		calls int64
+		try   func(c *client.Client) error
+	}{
+		{"TryLock", 1, func(c *client.Client) error {
+			ok, err := c.TryLock("order:1", "b", 5, 0)
+			if ok {
+				return errors.New("TryLock succeeded on a held lock")
+			}
+			return err
+		}},
+		{"TryLockNow", 1, func(c *client.Client) error {
+			ok, owner, _, err := c.TryLockNow("order:1", "b", 5)
+			if ok || owner != "a" {
+				return errors.New("TryLockNow = " + strconv.FormatBool(ok) + ", owner " + owner + "; want false, a")
+			}
+			return err
+		}},
+		{"MaxAttempts", 3, func(c *client.Client) error {
+			err := c.AcquireWith(context.Background(), writeSpec("order:1", "b"), client.AcquirePolicy{MaxAttempts: 3})
+			if !errors.Is(err, client.ErrMaxAttempts) {
+				return err
+			}
+			return nil
+		}},
+		{"LockUnless", 1, func(c *client.Client) error {
+			if _, err := c.LockUnless("order:1", "b", 5, "a"); err != client.ErrForbiddenOwner {
+				return errors.New("LockUnless err = " + errString(err) + "; want ErrForbiddenOwner")
+			}
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c, calls := countingClient(t)
+			c.Lock("order:1", "a", 30)
+			before := atomic.LoadInt64(calls)
+			if err := tt.try(c); err != nil {
+				t.Fatal(err)
+			}
+			if n := atomic.LoadInt64(calls) - before; n != tt.calls {
+				t.Fatalf("EvalSha calls = %d; want %d", n, tt.calls)
+			}
+			if n, err := c.WaiterCount("order:1"); err != nil || n != 0 {
+				t.Fatalf("WaiterCount = %d, %v; want 0", n, err)
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+// BenchmarkTryLockBusy
+// 锁被别人持有时只尝试一次的加锁，calls/op为每次加锁的EvalSha次数
+//
+//	go test ./client -run '^$' -bench TryLockBusy
+func BenchmarkTryLockBusy(b *testing.B) {
+	b.Run("TryLock", func(b *testing.B) {
+		c, calls := countingClient(b)
+		c.Lock("bench", "holder", 300)
+		benchBusy(b, calls, func() { c.TryLock("bench", "b", 5, 0) })
+	})
+	b.Run("TryLockNow", func(b *testing.B) {
+		c, calls := countingClient(b)
+		c.Lock("bench", "holder", 300)
+		benchBusy(b, calls, func() { c.TryLockNow("bench", "b", 5) })
+	})
+}
+
+func benchBusy(b *testing.B, calls *int64, try func()) {
+	b.ReportAllocs()
+	start := atomic.LoadInt64(calls)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		try()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(calls)-start)/float64(b.N), "calls/op")
+}
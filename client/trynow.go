@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TryLockNow
+// 只尝试一次写锁，不等待
+// 写锁被别人持有时返回false、当前的持有者和剩余时间（-1表示永不过期），和加锁在同一次脚本调用中得到，不增加请求；
+// 因为读者、排队顺序等原因失败时currentOwner为空
+func TryLockNow(key, uniqID string, expireTime int64) (acquired bool, currentOwner string, ttl time.Duration, err error) {
+	return std.TryLockNow(key, uniqID, expireTime)
+}
+
+// TryLockNow
+// 同 TryLockNow
+func (c *Client) TryLockNow(key, uniqID string, expireTime int64) (acquired bool, currentOwner string, ttl time.Duration, err error) {
+	if len(key) <= 0 {
+		return false, "", 0, errors.New("lock key is nil")
+	}
+	if c.Draining() {
+		return false, "", 0, ErrDraining
+	}
+	if c.conf().maxHeld > 0 && c.held.exceeds(LockCmd, key, uniqID, c.conf().maxHeld) {
+		return false, "", 0, ErrTooManyLocks
+	}
+	if c.conf().selfDeadlockCheck && c.held.selfConflict(LockCmd, key, uniqID) {
+		return false, "", 0, ErrSelfDeadlock
+	}
+	expireTime, err = c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return false, "", 0, err
+	}
+	ctx := withFullReply(context.Background())
+	// 失败时脚本直接离开等待队列，不用再发一次LEAVE
+	res, err := c.sendLock(ctx, key, uniqID, LockCmd, expireTime, withLastAttemptArg(nil)...)
+	if err != nil {
+		c.handleErrorContext(ctx, err)
+		return false, "", 0, err
+	}
+	if res.IsError() {
+		return false, "", 0, replyError(res)
+	}
+	if !res.Success() {
+		switch {
+		case len(res.Owner) <= 0:
+		case res.TTL < 0:
+			ttl = -1
+		default:
+			ttl = time.Duration(res.TTL) * time.Millisecond
+		}
+		return false, res.Owner, ttl, nil
+	}
+	c.held.track(LockCmd, key, uniqID)
+	recordLockAcquired(key)
+	c.emitEvent(EventAcquired, key, uniqID, res.Meta, nextAcquireID())
+	return true, "", 0, nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// RLockResult
+// TryRLock 的结果
+type RLockResult struct {
+	// 是否拿到了读锁
+	Acquired bool
+	// 加锁成功时的读者数量（包括自己）
+	Readers int
+	// 加锁失败时排在等待队列中、还在线的写者数量，不包括正在持有写锁的写者；加锁成功时为0
+	// 写者优先，读者要等这些写者都拿到并释放写锁之后才能加锁（开启 WithReadBatch 时可以提前放行一批读者）
+	// 写者很多时读者可以选择放弃，而不是长时间等待；排队的写者很多时只精确统计队首的一部分，其余按队列长度计数
+	PendingWriters int
+}
+
+// TryRLock
+// 只尝试一次读锁，不等待
+// 读锁被占用（有写锁、读者被冻结、冷却期等）时返回Acquired为false和nil，同时带上排队中的写者数量
+func TryRLock(key, uniqID string) (RLockResult, error) {
+	return std.TryRLock(key, uniqID)
+}
+
+// TryRLock
+// 同 TryRLock
+func (c *Client) TryRLock(key, uniqID string) (RLockResult, error) {
+	if len(key) <= 0 {
+		return RLockResult{}, errors.New("lock key is nil")
+	}
+	if c.Draining() {
+		return RLockResult{}, ErrDraining
+	}
+	rlockCmd, _, _ := c.readCmds()
+	if c.conf().maxHeld > 0 && c.held.exceeds(rlockCmd, key, uniqID, c.conf().maxHeld) {
+		return RLockResult{}, ErrTooManyLocks
+	}
+	ctx := withFullReply(context.Background())
+	res, err := c.sendLock(ctx, key, uniqID, rlockCmd, c.conf().readExpire, c.readExtra(rlockCmd, nil)...)
+	if err != nil {
+		c.handleErrorContext(ctx, err)
+		return RLockResult{}, err
+	}
+	if res.IsError() {
+		return RLockResult{}, replyError(res)
+	}
+	if !res.Success() {
+		return RLockResult{PendingWriters: res.Waiters}, nil
+	}
+	c.held.track(rlockCmd, key, uniqID)
+	return RLockResult{Acquired: true, Readers: res.Readers}, nil
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// LockUnless
+// 同只尝试一次的 TryLock，但锁正被forbiddenOwner持有（写锁或读锁）时返回false和 ErrForbiddenOwner
+// 持有者的检查在脚本中和加锁一起完成，是原子的；forbiddenOwner为空时等同于 TryLock
+func LockUnless(key, uniqID string, expire int64, forbiddenOwner string) (bool, error) {
+	return std.LockUnless(key, uniqID, expire, forbiddenOwner)
+}
+
+// LockUnless
+// 同 LockUnless
+func (c *Client) LockUnless(key, uniqID string, expire int64, forbiddenOwner string) (bool, error) {
+	expire, err := c.normalizeExpire(key, expire)
+	if err != nil {
+		return false, err
+	}
+	// ARGV[3]~ARGV[8]：排队位置标记、元数据、值的key、分配fencing token、最短剩余时间、不允许的持有者
+	_, err = c.acquireReply(context.Background(), key, uniqID, LockCmd, expire, AcquirePolicy{MaxAttempts: 1}, "", "", "", "", "", forbiddenOwner)
+	if errors.Is(err, ErrMaxAttempts) {
+		return false, nil
+	}
+	return err == nil, err
+}
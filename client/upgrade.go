@@ -0,0 +1,42 @@
+package client
+
+import "context"
+
+// Upgrade
+// 把uniqID持有的读锁升级为写锁，等待其他读者全部释放
+// 两个读者同时阻塞升级时会互相等待，这时先登记升级意向的一方继续等待，
+// 后来的一方返回 ErrUpgradeDeadlock，它应当 RUnlockID 释放读锁让先来的一方完成升级，之后再重新加锁
+// ctx取消时返回ctx.Err()并撤销升级意向，读锁仍然持有
+func Upgrade(ctx context.Context, key, uniqID string, expireTime int64) error {
+	return std.Upgrade(ctx, key, uniqID, expireTime)
+}
+
+// Upgrade
+// 同 Upgrade
+func (c *Client) Upgrade(ctx context.Context, key, uniqID string, expireTime int64) error {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return err
+	}
+	_, err = c.acquireReply(ctx, key, uniqID, UpgradeCmd, expireTime, AcquirePolicy{}, "1")
+	return err
+}
+
+// TryUpgrade
+// 尝试把uniqID持有的读锁升级为写锁，只有自己是唯一的读者时才会成功
+// 有其他读者或写锁时立即返回false，不会等待
+// 两个读者同时阻塞升级会互相等待形成死锁，所以返回false时调用方应当先
+// RUnlockID 释放读锁，再重新 Lock 获取写锁
+func TryUpgrade(key, uniqID string, expireTime int64) (bool, error) {
+	return std.TryUpgrade(key, uniqID, expireTime)
+}
+
+// TryUpgrade
+// 同 TryUpgrade
+func (c *Client) TryUpgrade(key, uniqID string, expireTime int64) (bool, error) {
+	expireTime, err := c.normalizeExpire(key, expireTime)
+	if err != nil {
+		return false, err
+	}
+	return c.sendOnce(context.Background(), key, uniqID, UpgradeCmd, expireTime)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate
+// 检查options组合在一起是否一致，有问题时返回 ErrInvalidConfig，错误信息中列出全部问题
+// DoInit 和 NewClient 会先调用它，也可以在启动前单独调用
+// 单个option传入的无效值（如小于等于0）按各自的说明忽略，不算问题；这里检查的是会在运行时才暴露的组合
+func Validate(options ...Option) error {
+	c := defaultConfig()
+	for _, o := range options {
+		o(c)
+	}
+	return c.validate()
+}
+
+// validate
+// 同 Validate
+func (c *config) validate() error {
+	var problems []string
+	if c.notifyFallback > 0 && !c.notify {
+		problems = append(problems, "notify fallback interval has no effect without WithNotify")
+	}
+	if c.maxAcquireDuration > 0 {
+		if c.initialJitter >= c.maxAcquireDuration {
+			problems = append(problems, fmt.Sprintf("initial jitter %s is not shorter than max acquire duration %s", c.initialJitter, c.maxAcquireDuration))
+		}
+		if c.maxAcquireDuration < c.backoffMin {
+			problems = append(problems, fmt.Sprintf("max acquire duration %s is shorter than min backoff %s, acquires get a single attempt", c.maxAcquireDuration, c.backoffMin))
+		}
+		if c.slowAcquire >= c.maxAcquireDuration {
+			problems = append(problems, fmt.Sprintf("slow acquire threshold %s is not shorter than max acquire duration %s, the callback never fires", c.slowAcquire, c.maxAcquireDuration))
+		}
+	}
+	if c.pool.size > 0 && c.pool.minIdle > c.pool.size {
+		problems = append(problems, fmt.Sprintf("min idle conns %d exceeds pool size %d", c.pool.minIdle, c.pool.size))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	redis "github.com/go-redis/redis/v8"
+)
+
+// 通知channel上发布的事件，和lock.lua保持一致
+const (
+	// 写锁释放
+	NotifyUnlock = "unlock"
+	// 最后一个读者释放
+	NotifyRUnlock = "runlock"
+	// 解除读者冻结
+	NotifyUnfreeze = "unfreeze"
+	// 写锁加锁成功，只有开启 WithAcquireNotify 时发布
+	NotifyAcquire = "acquire"
+)
+
+// WatchPrefix 的channel缓冲大小，消费不及时时丢弃新的事件
+const watchBuffer = 64
+
+// 重连后重新订阅失败时的退避：从watchRetryMin开始翻倍，最多 maxReconnectInterval，连续失败watchRetryLimit次后关闭channel
+const (
+	watchRetryMin   = 100 * time.Millisecond
+	watchRetryLimit = 10
+)
+
+// LockEvent
+// WatchPrefix 收到的通知
+type LockEvent struct {
+	Key string
+	// NotifyUnlock、NotifyRUnlock、NotifyUnfreeze 或 NotifyAcquire
+	Type string
+	// 本进程收到通知的时间
+	Time time.Time
+}
+
+// WatchPrefix
+// 观察key以prefix开头的所有锁的释放（开启 WithAcquireNotify 时还有加写锁）事件，prefix为空时观察所有的锁
+// 使用单独的PSUBSCRIBE，不需要开启 WithNotify；重连后自动在新的客户端上重新订阅，重连期间的事件会丢失
+// 重新订阅失败时按退避重试，一直失败时关闭channel，调用方可以重新调用 WatchPrefix
+// 通知是尽力而为的：消费不及时时丢弃新的事件，只能作为重新检查锁状态的信号
+// ctx取消或者客户端关闭后channel被关闭
+func WatchPrefix(ctx context.Context, prefix string) (<-chan LockEvent, error) {
+	return std.WatchPrefix(ctx, prefix)
+}
+
+// WatchPrefix
+// 同 WatchPrefix
+func (c *Client) WatchPrefix(ctx context.Context, prefix string) (<-chan LockEvent, error) {
+	if c.conn() == nil {
+		return nil, ErrNotInitialized
+	}
+	w := &watcher{
+		pattern: notifyChannelPrefix + escapeGlob(prefix) + "*",
+		out:     make(chan LockEvent, watchBuffer),
+		done:    make(chan struct{}),
+	}
+	if err := w.subscribe(c.conn()); err != nil {
+		return nil, err
+	}
+	c.watches.add(w)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-w.done:
+		}
+		c.watches.remove(w)
+		w.close()
+	}()
+	return w.out, nil
+}
+
+// escapeGlob
+// 转义PSUBSCRIBE模式中的特殊字符，prefix按字面匹配
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// watcher
+// 一个 WatchPrefix 的订阅
+type watcher struct {
+	pattern string
+	out     chan LockEvent
+
+	mu     sync.Mutex
+	ps     *redis.PubSub
+	closed bool
+	// 客户端关闭时通知清理协程
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// subscribe
+// 在c上订阅，替换旧的订阅
+func (w *watcher) subscribe(c redis.UniversalClient) error {
+	ps := c.PSubscribe(context.Background(), w.pattern)
+	if _, err := ps.Receive(context.Background()); err != nil {
+		_ = ps.Close()
+		return err
+	}
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		_ = ps.Close()
+		return nil
+	}
+	old := w.ps
+	w.ps = ps
+	w.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	go w.run(ps)
+	return nil
+}
+
+// run
+// 转发订阅到的通知，订阅关闭后退出
+func (w *watcher) run(ps *redis.PubSub) {
+	for msg := range ps.Channel() {
+		ev := LockEvent{
+			Key:  strings.TrimPrefix(msg.Channel, notifyChannelPrefix),
+			Type: msg.Payload,
+			Time: time.Now(),
+		}
+		w.mu.Lock()
+		if !w.closed && w.ps == ps {
+			select {
+			case w.out <- ev:
+			default:
+			}
+		}
+		w.mu.Unlock()
+	}
+}
+
+// close
+// 关闭订阅和channel，可以重复调用
+func (w *watcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	if w.ps != nil {
+		_ = w.ps.Close()
+	}
+	close(w.out)
+}
+
+// resubscribe
+// 在c上重新订阅，失败时按退避重试
+// 重试期间又发生了重连（current不再是c）时退出，由新的重连负责；一直失败时结束订阅，关闭channel
+func (w *watcher) resubscribe(c redis.UniversalClient, current func() redis.UniversalClient, logger Logger, retryMin time.Duration, retryLimit int) {
+	delay := retryMin
+	for attempt := 1; ; attempt++ {
+		err := w.subscribe(c)
+		if err == nil {
+			return
+		}
+		if attempt >= retryLimit {
+			logger.Printf("resubscribe %s failed %d times, closing the watch: %v", w.pattern, attempt, err)
+			w.doneOnce.Do(func() { close(w.done) })
+			return
+		}
+		logger.Printf("resubscribe %s failed, retry in %s: %v", w.pattern, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if current() != c {
+			return
+		}
+		if delay *= 2; delay > maxReconnectInterval {
+			delay = maxReconnectInterval
+		}
+	}
+}
+
+// watchRegistry
+// 客户端上所有的 WatchPrefix 订阅
+type watchRegistry struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+	// 重新订阅的退避，见 watchRetryMin、watchRetryLimit
+	retryMin   time.Duration
+	retryLimit int
+}
+
+// newWatchRegistry
+// 创建空的订阅登记
+func newWatchRegistry() *watchRegistry {
+	return &watchRegistry{
+		watchers:   make(map[*watcher]struct{}),
+		retryMin:   watchRetryMin,
+		retryLimit: watchRetryLimit,
+	}
+}
+
+func (r *watchRegistry) add(w *watcher) {
+	r.mu.Lock()
+	r.watchers[w] = struct{}{}
+	r.mu.Unlock()
+}
+
+func (r *watchRegistry) remove(w *watcher) {
+	r.mu.Lock()
+	delete(r.watchers, w)
+	r.mu.Unlock()
+}
+
+// list
+// 返回当前所有订阅的副本
+func (r *watchRegistry) list() []*watcher {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ret := make([]*watcher, 0, len(r.watchers))
+	for w := range r.watchers {
+		ret = append(ret, w)
+	}
+	return ret
+}
+
+// restart
+// 重连后在新的客户端上重新订阅，每个订阅在自己的协程中重试，不阻塞重连
+// current返回客户端当前使用的redis连接
+func (r *watchRegistry) restart(c redis.UniversalClient, current func() redis.UniversalClient, logger Logger) {
+	for _, w := range r.list() {
+		go w.resubscribe(c, current, logger, r.retryMin, r.retryLimit)
+	}
+}
+
+// closeAll
+// 客户端关闭时结束所有订阅
+func (r *watchRegistry) closeAll() {
+	for _, w := range r.list() {
+		w.doneOnce.Do(func() { close(w.done) })
+	}
+}
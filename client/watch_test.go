@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+)
+
+type discardLogger struct{}
+
+func (discardLogger) Printf(format string, v ...interface{}) {}
+
+func newWatchClient(t *testing.T) (*Client, *miniredis.Miniredis) {
+	m := miniredis.RunT(t)
+	c, err := NewClient(&redis.Options{Addr: m.Addr(), MaxRetries: -1, DialTimeout: 100 * time.Millisecond},
+		WithEvictionCheck(EvictionIgnore), WithLogger(discardLogger{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.watches.retryMin = 10 * time.Millisecond
+	return c, m
+}
+
+func publish(t *testing.T, addr, key string) {
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	defer rdb.Close()
+	if err := rdb.Publish(context.Background(), notifyChannelPrefix+key, NotifyUnlock).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 重新订阅失败后按退避重试，redis恢复后继续收到事件
+func TestWatchResubscribeRetries(t *testing.T) {
+	c, m := newWatchClient(t)
+	events, err := c.WatchPrefix(context.Background(), "order:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Close()
+	c.watches.restart(c.conn(), c.conn, c.conf().logger)
+	time.Sleep(50 * time.Millisecond)
+	if err := m.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		publish(t, m.Addr(), "order:1")
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("the watch closed although redis came back")
+			}
+			if ev.Key != "order:1" || ev.Type != NotifyUnlock {
+				t.Fatalf("event = %+v", ev)
+			}
+			return
+		case <-deadline:
+			t.Fatal("no event after redis came back")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// 一直失败时关闭channel，调用方不会永远等在一个没有订阅的channel上
+func TestWatchResubscribeGivesUp(t *testing.T) {
+	c, m := newWatchClient(t)
+	c.watches.retryLimit = 3
+	events, err := c.WatchPrefix(context.Background(), "order:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Close()
+	c.watches.restart(c.conn(), c.conn, c.conf().logger)
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("got an event from a dead redis")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch stayed open after every resubscribe failed")
+	}
+	if n := len(c.watches.list()); n != 0 {
+		t.Fatalf("%d watchers still registered", n)
+	}
+}
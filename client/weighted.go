@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// 默认的按权重读锁的总容量
+const DefaultReadCapacity int64 = 100
+
+// RLockWeighted
+// 按权重加读锁，占用weight个单位的容量，剩余容量不足时等待
+// 每个key的总容量由 WithReadCapacity 设置，写锁需要全部容量（没有任何读者）才能获得
+// 同一个uniqID重复加锁时占用的容量累加，expireTime小于等于0时使用配置的读锁过期时间
+func RLockWeighted(key, uniqID string, weight, expireTime int64) error {
+	return std.RLockWeighted(key, uniqID, weight, expireTime)
+}
+
+// RLockWeighted
+// 同 RLockWeighted
+func (c *Client) RLockWeighted(key, uniqID string, weight, expireTime int64) error {
+	if expireTime > MaxExpire {
+		return ErrInvalidExpire
+	}
+	spec := LockSpec{Type: LockTypeWeighted, Key: key, Token: uniqID, TTL: time.Duration(expireTime) * time.Second, Weight: weight}
+	return c.AcquireWith(context.Background(), spec, AcquirePolicy{})
+}
+
+// RUnlockWeighted
+// 释放按权重的读锁，归还weight个单位的容量，全部归还后读者被删除
+func RUnlockWeighted(key, uniqID string, weight int64) error {
+	return std.RUnlockWeighted(key, uniqID, weight)
+}
+
+// RUnlockWeighted
+// 同 RUnlockWeighted
+func (c *Client) RUnlockWeighted(key, uniqID string, weight int64) error {
+	if weight <= 0 {
+		return errors.New("invalid weight")
+	}
+	_, err := c.sendOnce(context.Background(), key, uniqID, WRUnlockCmd, 0, strconv.FormatInt(weight, 10))
+	return err
+}
@@ -3,7 +3,9 @@ module github.com/lzw5399/rwlock
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/onsi/gomega v1.31.1 // indirect
 	github.com/sony/sonyflake v1.0.0
+	golang.org/x/sync v0.5.0
 )
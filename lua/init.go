@@ -8,6 +8,10 @@ import (
 
 var ScriptContent string
 
+// Version
+// 脚本版本，需要和lock.lua中的scriptVersion保持一致
+const Version = "2"
+
 // Lua脚本文件名
 var scriptName = "lock.lua"
 
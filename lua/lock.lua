@@ -1,6 +1,12 @@
 -- 基于lua的读写锁，可以保证原子性
 -- Redis对Lua的支持是有限制，不支持require，只能做成单文件
 
+-- 脚本中用到了TIME，需要按效果复制（Redis 5以上默认开启）
+if redis.replicate_commands ~= nil
+then
+    redis.replicate_commands()
+end
+
 local rProfix = "_read_for_lock__"
 local wProfix = "_write_for_lock__"
 local lockKey = KEYS[1]
@@ -22,22 +28,107 @@ end
 --超时时间
 local expireNum = tonumber(ARGV[2])
 
--- 客户端是否等待监测
-local onlineKey = "_waiting_exipre_lock_key__" .. lockKey .. "_uniqueID__" ..lockUniqKey
+-- 加写锁失败时是否需要返回自己在等待队列中的位置
+local wantPosition = ARGV[3] == "1"
+
+-- 等待队列的过期时间（秒），所有等待者都离开后队列会自动过期
+local waitQueueExpire = 10
+
+-- 加写锁时要求的最短剩余时间（毫秒），0表示不要求
+local minTTL = 0
 
 
 --读锁key
 local readLockKey = rProfix .. lockKey
 --写锁key
 local writeLockKey = wProfix .. lockKey
+--按权重占用容量的读者 member为读者的uniqID score为过期的毫秒时间戳
+local weightedKey = "_weighted_for_lock__" .. lockKey
+--按权重占用容量的读者的权重 field为读者的uniqID value为占用的容量
+local weightKey = "_weights_for_lock__" .. lockKey
+--写锁持有者附带的元数据，过期时间和写锁一致
+local metaKey = "_meta_for_lock__" .. lockKey
+--阻塞升级的意向 value为登记升级意向的读者uniqID
+local upgradeIntentKey = "_upgrade_intent_for_lock__" .. lockKey
+--带过期时间的读者集合 member为读者的uniqID score为过期的毫秒时间戳
+local readersKey = "_readers_for_lock__" .. lockKey
+--读者的重入次数 field为读者的uniqID value为重入次数
+local readerCountKey = "_reader_counts_for_lock__" .. lockKey
+-- 共享过期时间模式下的读者计数，所有读者共用这个key的过期时间
+local sharedReadKey = "_shared_read_for_lock__" .. lockKey
+-- 等待中的读者，score为写者开始让路的时间（毫秒）
+local readerWaitKey = "_reader_wait__" .. lockKey
+-- 等待中的读者最近一次重试的时间，超过readerWaitStale毫秒没有重试的读者视为已经放弃
+local readerSeenKey = "_reader_wait_seen__" .. lockKey
+local readerWaitStale = 3000
+-- 读者的公平窗口（毫秒），由加读锁的指令传入，0表示不登记等待
+local readerWindow = 0
+-- 写锁持有者的记录，锁过期后仍保留holderGrace秒，用于知道锁是从谁手里接管的
+-- 写者等待期间放行的读者数量，写者加锁成功或者没有写者等待时清零
+local readBatchKey = "_read_batch__" .. lockKey
+-- 写者等待期间最多放行的读者数量，由加读锁的指令传入，0表示不限制
+local readBatch = 0
+-- 维护期间停止接纳新的读者，value为冻结的操作者，一定带过期时间
+local freezeKey = "_reader_freeze__" .. lockKey
+-- 软释放的持有者，宽限期内同一个持有者可以立即重新加锁
+local softReleaseKey = "_soft_release__" .. lockKey
+-- 释放写锁后的冷却期，存在时不能加锁
+local cooldownKey = "_cooldown_for_lock__" .. lockKey
+local holderKey = "_holder_for_lock__" .. lockKey
+local holderGrace = 3600
+-- 写锁的fencing token计数器，只增不减，不设置过期时间
+local fenceKey = "_fence_for_lock__" .. lockKey
 local errorString = ""
 local debugString = ""
 local Ok =  "OK"
 
+-- 脚本版本，修改数据结构或返回值时需要同步修改 lua.Version
+local scriptVersion = "2"
+
+-- STATUS 指令返回的锁状态
+local statusOwner = ""
+local statusTTL = 0
+local statusReaders = 0
+local statusWaiters = 0
+local statusMeta = ""
+-- RLOCKGET/LOCKGET加锁成功后读取到的值，key不存在时为空
+local statusValue = ""
+-- 接管了过期未释放的锁时，上一个持有者的uniqID和元数据
+local statusPrevOwner = ""
+local statusPrevMeta = ""
+-- 加写锁成功时分配的fencing token，0表示没有分配
+local statusFence = 0
+-- 写锁持有者加锁时附带的trace ID
+local statusTrace = ""
+-- 加锁因为冷却期失败时，冷却期剩余的毫秒数
+local statusCooldown = 0
+-- 未过期的读者及剩余时间，为空时不返回（cjson会把空表编码成对象）
+local statusReaderList = nil
+local queuePosition = 0
+-- 单次调用最多清理的过期记录数量，避免读者很多时脚本执行时间超过lua-time-limit
+local cleanLimit = 100
+-- 是否还有没清理完的过期记录，需要继续调用GC
+local moreClean = false
+-- 本次调用清理的过期记录数量
+local cleaned = 0
+
 local function getOnlineKey(uniqKey)
     return "_online_exipre_lock_key__" .. lockKey .. "_uniqueID__" .. uniqKey
 end
 
+-- 客户端是否等待监测
+local onlineKey = getOnlineKey(lockUniqKey)
+
+-- 通知等待者锁已经释放
+local function publishRelease(event)
+    redis.call("PUBLISH", "rwlock:notify:" .. lockKey, event)
+end
+
+-- KEYS[4]为"pub"时加写锁成功也发布通知，供按前缀观察的客户端使用
+local publishAcquire = KEYS[4] == "pub"
+-- KEYS[5]为审计stream的最大长度，大于0时把锁的变化追加到stream
+local auditMaxLen = tonumber(KEYS[5]) or 0
+
 local function get(key)
     return redis.call("GET", key)
 end
@@ -89,6 +180,22 @@ local function exists(key)
     return  redis.call("EXISTS" , key)
 end
 
+-- 是否冻结了新的读者
+local function readersFrozen()
+    return exists(freezeKey) > 0
+end
+
+-- 是否在释放写锁后的冷却期内，是的话记录剩余的毫秒数
+local function coolingDown()
+    local pttl = redis.call("PTTL", cooldownKey)
+    if pttl > 0
+    then
+        statusCooldown = pttl
+        return true
+    end
+    return false
+end
+
 local function rpush(key , val)
     return redis.call("RPUSH" , key , val)
 end
@@ -109,6 +216,164 @@ local function range(key , startIdx , endIdx)
     return redis.call("LRANGE",key ,startIdx ,endIdx)
 end
 
+-- 当前的毫秒时间戳
+local function nowMs()
+    local t = redis.call("TIME")
+    return tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+end
+
+-- 设置写锁的元数据，过期时间和写锁保持一致，meta为空时删除
+local function setMeta(meta)
+    if meta == nil or string.len(meta) <= 0
+    then
+        del(metaKey)
+        return
+    end
+    redis.call("SET", metaKey, meta)
+    local pttl = redis.call("PTTL", writeLockKey)
+    if pttl > 0
+    then
+        redis.call("PEXPIRE", metaKey, pttl)
+    end
+end
+
+-- 刷新持有者记录的过期时间，比写锁多保留holderGrace秒
+local function refreshHolder()
+    if expireNum > 0
+    then
+        expire(holderKey, expireNum + holderGrace)
+    else
+        redis.call("PERSIST", holderKey)
+    end
+end
+
+-- 记录新的持有者
+-- 正常释放会删除记录，所以记录中还有别的持有者时，说明它的锁是过期的，返回给调用方
+local function setHolder(meta, trace)
+    local prev = redis.call("HMGET", holderKey, "owner", "meta")
+    if prev[1] and prev[1] ~= lockUniqKey
+    then
+        statusPrevOwner = prev[1]
+        statusPrevMeta = prev[2] or ""
+    end
+    redis.call("HSET", holderKey, "owner", lockUniqKey, "meta", meta or "", "trace", trace or "")
+    refreshHolder()
+end
+
+-- 读者集合的过期时间不能短于ms（最晚过期的读者），附带的hash和集合一起过期
+-- hash被删空后重新创建时没有过期时间，集合的过期时间又可能已经足够长，所以每次都要检查hash
+local function expireReaderSet(setKey, hashKey, ms)
+    if redis.call("PTTL", setKey) < ms
+    then
+        redis.call("PEXPIRE", setKey, ms)
+    end
+    local pttl = redis.call("PTTL", setKey)
+    local hashTTL = redis.call("PTTL", hashKey)
+    if pttl > 0 and (hashTTL == -1 or hashTTL < pttl)
+    then
+        redis.call("PEXPIRE", hashKey, pttl)
+    end
+end
+
+-- 删除一个读者的记录
+local function removeReader(uniqID)
+    redis.call("ZREM", readersKey, uniqID)
+    hdel(readerCountKey, uniqID)
+end
+
+-- 清理已过期的按权重的读者，返回剩余的数量和占用的总容量
+-- 总容量受readCapacity限制，遍历权重的开销是有上限的
+local function liveWeighted()
+    local now = nowMs()
+    local expired = redis.call("ZRANGEBYSCORE", weightedKey, "-inf", now, "LIMIT", 0, cleanLimit)
+    for _, id in ipairs(expired)
+    do
+        redis.call("ZREM", weightedKey, id)
+        hdel(weightKey, id)
+    end
+    cleaned = cleaned + #expired
+    if #expired >= cleanLimit
+    then
+        moreClean = true
+    end
+    local used = 0
+    for _, w in ipairs(redis.call("HVALS", weightKey))
+    do
+        used = used + tonumber(w)
+    end
+    return redis.call("ZCOUNT", weightedKey, "(" .. now, "+inf"), used
+end
+
+-- 共享过期时间模式下的读者数量，过期后key被redis删除
+local function sharedReaders()
+    local n = get(sharedReadKey)
+    if n == false or tonumber(n) <= 0
+    then
+        return 0
+    end
+    return tonumber(n)
+end
+
+-- 清理已过期的读者，返回剩余读者数量（包括按权重的读者和共享过期时间的读者）
+-- 每次最多清理cleanLimit个，没清理完的过期读者不计入数量
+local function liveReaders()
+    local now = nowMs()
+    local expired = redis.call("ZRANGEBYSCORE", readersKey, "-inf", now, "LIMIT", 0, cleanLimit)
+    for _, id in ipairs(expired)
+    do
+        removeReader(id)
+    end
+    cleaned = cleaned + #expired
+    if #expired >= cleanLimit
+    then
+        moreClean = true
+    end
+    local weighted = liveWeighted()
+    return redis.call("ZCOUNT", readersKey, "(" .. now, "+inf") + weighted + sharedReaders()
+end
+
+
+-- 读者因为写锁加锁失败时登记等待，第一次登记的时间加上窗口就是写者开始让路的时间
+local function readerWait()
+    if readerWindow <= 0
+    then
+        return
+    end
+    local now = nowMs()
+    redis.call("ZADD", readerWaitKey, "NX", now + readerWindow, lockUniqKey)
+    hset(readerSeenKey, lockUniqKey, now)
+    expire(readerWaitKey, waitQueueExpire)
+    expire(readerSeenKey, waitQueueExpire)
+end
+
+-- 读者加锁成功，不再等待
+local function readerDone()
+    if readerWindow <= 0
+    then
+        return
+    end
+    redis.call("ZREM", readerWaitKey, lockUniqKey)
+    hdel(readerSeenKey, lockUniqKey)
+end
+
+-- 是否有等待超过公平窗口、并且还在重试的读者，有的话写者让路
+-- 最多检查cleanLimit个，顺便清理已经不再重试的读者
+local function readerStarving()
+    local now = nowMs()
+    local ids = redis.call("ZRANGEBYSCORE", readerWaitKey, "-inf", now, "LIMIT", 0, cleanLimit)
+    for _, id in ipairs(ids)
+    do
+        local seen = redis.call("HGET", readerSeenKey, id)
+        if seen and now - tonumber(seen) <= readerWaitStale
+        then
+            return true
+        end
+        redis.call("ZREM", readerWaitKey, id)
+        hdel(readerSeenKey, id)
+    end
+    return false
+end
+
 
 -- ------- 公平锁逻辑 ------
 -- 刷新hearbeat
@@ -161,6 +426,29 @@ local function front()
     return lindex(queueKey , 0)
 end
 
+-- 读者准入：有在线的写者等待时，只放行readBatch个读者，之后拒绝新的读者，等写者加锁
+-- 没有写者等待时读者不受限制，也不计数
+local function readBatchFull()
+    if readBatch <= 0
+    then
+        return false
+    end
+    local f = front()
+    if f == false or not isOnline(f)
+    then
+        del(readBatchKey)
+        return false
+    end
+    local n = tonumber(get(readBatchKey) or "0")
+    if n >= readBatch
+    then
+        return true
+    end
+    incr(readBatchKey)
+    expire(readBatchKey, waitQueueExpire)
+    return false
+end
+
 -- 队列的长度
 
 local function countQueue()
@@ -204,7 +492,72 @@ local function handleLockFail()
 
 --  自身入队
     enQueue()
+--  有人等待时刷新队列的过期时间，崩溃的等待者留下的队列会自动过期
+    expire(queueKey, waitQueueExpire)
+    expire(existHashKey, waitQueueExpire)
+
+--  调用方需要时才计算位置，避免每次都遍历队列
+    if wantPosition
+    then
+        local ids = range(queueKey, 0, -1)
+        for i, id in ipairs(ids)
+        do
+            if id == lockUniqKey
+            then
+                queuePosition = i
+                break
+            end
+        end
+    end
+end
+
+-- 放弃等待，离开等待队列，避免排在队首时耽误后面的等待者
+local function leaveQueue()
+    lrem(queueKey, 0, lockUniqKey)
+    hdel(existHashKey, lockUniqKey)
+    del(onlineKey)
+    -- 放弃阻塞升级时撤销自己的升级意向
+    if get(upgradeIntentKey) == lockUniqKey
+    then
+        del(upgradeIntentKey)
+    end
+    return true
+end
 
+-- 清理队列中已经不在线的等待者，返回在线的等待者数量
+-- 每次最多检查cleanLimit个，剩下的直接计入数量
+local function cleanWaiters()
+    local ids = range(queueKey, 0, cleanLimit - 1)
+    local beyond = countQueue() - #ids
+    local count = beyond
+    local removed = 0
+    for _, id in ipairs(ids)
+    do
+        if isOnline(id)
+        then
+            count = count + 1
+        else
+            lrem(queueKey, 1, id)
+            hdel(existHashKey, id)
+            removed = removed + 1
+        end
+    end
+    cleaned = cleaned + removed
+    -- 这次清理了记录时，窗口后面的等待者移到了前面，下一次调用会检查到它们
+    -- 窗口内全部在线时不再继续，后面在线的等待者不需要清理，否则排队超过cleanLimit个写者时GC永远停不下来
+    if removed > 0 and beyond > 0
+    then
+        moreClean = true
+    end
+    return count
+end
+-- 读锁被占用时回馈排队中的写者数量，读者据此决定继续等待还是放弃
+local function readResult(ok)
+    if not ok and string.len(errorString) <= 0
+    then
+        statusWaiters = cleanWaiters()
+    end
+    return ok
 end
 --处理加锁成功的情况
 local function handleLockSuccess()
@@ -219,11 +572,55 @@ local function handleLockSuccess()
     hdel(existHashKey, lockUniqKey)
 end
 
+-- 写锁加锁成功后的记录：元数据、持有者、fencing token、出队
+local function lockGranted()
+    -- ARGV[4]为加锁时附带的元数据
+    setMeta(ARGV[4])
+    -- ARGV[9]为加锁时附带的trace ID，和持有者记录在一起
+    setHolder(ARGV[4], ARGV[9])
+    -- 回馈本次加锁的元数据，客户端用于事件
+    if ARGV[4] ~= nil
+    then
+        statusMeta = ARGV[4]
+    end
+    -- ARGV[6]为"1"时分配fencing token，每次加锁成功加一
+    if ARGV[6] == "1"
+    then
+        statusFence = redis.call("INCR", fenceKey)
+    end
+    -- 写者进来了，下一个写者等待时重新计数
+    del(readBatchKey)
+--    处理加锁成功
+    handleLockSuccess()
+    if publishAcquire
+    then
+        publishRelease("acquire")
+    end
+    return true
+end
+
+-- 软释放的宽限期内同一个持有者重新加锁，不用排队，直接恢复过期时间
+local function regrant()
+    del(softReleaseKey)
+    if expireNum > 0
+    then
+        expire(writeLockKey, expireNum)
+    else
+        redis.call("PERSIST", writeLockKey)
+    end
+    return lockGranted()
+end
+
 -- write lock
 local function lock()
     --   维护一下自己的心跳
     onlineHeartbeat()
 
+    if get(writeLockKey) == lockUniqKey and get(softReleaseKey) == lockUniqKey
+    then
+        return regrant()
+    end
+
     -- 如果有读锁在用
     -- 直接则返回false
     -- 表示上锁失败
@@ -235,6 +632,13 @@ local function lock()
         handleLockFail()
         return false
     end
+    local readers = liveReaders()
+    if readers > 0
+    then
+        debugString = "readers number(".. readers ..") > 0"
+        handleLockFail()
+        return false
+    end
     -- 表示写锁已经被加上
     -- 写锁失败
     -- 返回false
@@ -242,10 +646,26 @@ local function lock()
     if wret ~= false and string.len(wret) > 0
     then
         debugString = "write lock be set by other"
+        -- 回馈当前的持有者和剩余时间，失败的调用方不用再查一次状态
+        statusOwner = wret
+        statusTTL = redis.call("PTTL", writeLockKey)
 --        锁被别人占用了，mmp
         handleLockFail()
         return false
     end
+    if coolingDown()
+    then
+        debugString = "lock is cooling down,key==" .. lockKey
+        handleLockFail()
+        return false
+    end
+    -- 有读者等待超过了公平窗口，先让读者进来
+    if readerStarving()
+    then
+        debugString = "readers waiting longer than fairness window"
+        handleLockFail()
+        return false
+    end
 --    检查是否轮到自己
     local isTurnMe = isSelf()
     if isTurnMe == false
@@ -262,18 +682,26 @@ local function lock()
         debugString = "write lock set fail,key=" .. writeLockKey .. ",lockUniqKey=" .. lockUniqKey
         return false
     end
-    -- 设置过期时间
-    local expireRet = expire(writeLockKey, expireNum)
-    if expireRet <= 0
+    -- 设置过期时间，expireNum小于等于0表示永不过期
+    if expireNum > 0
     then
-        -- 回滚
-        del(writeLockKey)
-        debugString = "write lock expire fail,key=" .. writeLockKey .. ",expireNum=" .. expireNum
-        return false
+        local expireRet = expire(writeLockKey, expireNum)
+        if expireRet <= 0
+        then
+            -- 回滚
+            del(writeLockKey)
+            debugString = "write lock expire fail,key=" .. writeLockKey .. ",expireNum=" .. expireNum
+            return false
+        end
+        -- 实际拿到的剩余时间不够时回滚，不能给出一把马上就过期的锁
+        if minTTL > 0 and redis.call("PTTL", writeLockKey) < minTTL
+        then
+            del(writeLockKey)
+            errorString = "lease shorter than min ttl"
+            return false
+        end
     end
---    处理加锁成功
-    handleLockSuccess()
-    return true
+    return lockGranted()
 end
 
 local function unlock()
@@ -292,6 +720,20 @@ local function unlock()
         debugString = "write unlock one timeout key ,key==" .. writeLockKey .. ",expectUniqKey=" .. lockUniqKey .. ",newUniqKey=" .. ret
         return true
     end
+    -- ARGV[4]为软释放的宽限期（毫秒）：锁先保留宽限期，期间自己重新加锁立即成功，没有重新加锁就自然过期
+    local grace = tonumber(ARGV[4]) or 0
+    if grace > 0
+    then
+        redis.call("PEXPIRE", writeLockKey, grace)
+        if exists(metaKey) > 0
+        then
+            redis.call("PEXPIRE", metaKey, grace)
+        end
+        redis.call("SET", softReleaseKey, lockUniqKey, "PX", grace)
+        statusOwner = lockUniqKey
+        return true
+    end
+    del(softReleaseKey)
     -- 删除当前的key
     local retDel = del(writeLockKey)
     if retDel <= 0
@@ -299,6 +741,22 @@ local function unlock()
         debugString = "write unlock del fail,key==" .. writeLockKey
         return false
     end
+    -- 回馈真正释放的持有者和它的元数据，客户端用于事件
+    statusOwner = lockUniqKey
+    local meta = get(metaKey)
+    if meta ~= false
+    then
+        statusMeta = meta
+    end
+    del(metaKey)
+    del(holderKey)
+    -- ARGV[3]为释放后的冷却期（毫秒），期间不能加锁
+    local cooldown = tonumber(ARGV[3]) or 0
+    if cooldown > 0
+    then
+        redis.call("SET", cooldownKey, "1", "PX", cooldown)
+    end
+    publishRelease("unlock")
 
     return true
 end
@@ -310,12 +768,50 @@ local function rlock()
     if wlock ~= false and string.len(wlock) > 0
     then
         debugString = "read rlock fail,write lock occupy now,key==" .. writeLockKey .. ",occupyUniqKey=" .. wlock
+        readerWait()
+        return false
+    end
+    if readersFrozen()
+    then
+        debugString = "read rlock fail,readers frozen,key==" .. lockKey
+        return false
+    end
+    if coolingDown()
+    then
+        debugString = "read rlock fail,lock is cooling down,key==" .. lockKey
+        return false
+    end
+    if readBatchFull()
+    then
+        debugString = "read rlock fail,read batch full,writer waiting,key==" .. lockKey
+        readerWait()
         return false
     end
+    readerDone()
+
+    -- 带uniqID的读者按过期时间登记，同一个读者重复加锁只增加重入次数
+    if string.len(lockUniqKey) > 0
+    then
+        -- 先清理过期的读者，避免过期读者残留的重入次数被继续累加
+        liveReaders()
+        local expireAt = nowMs() + expireNum * 1000
+        redis.call("ZADD", readersKey, expireAt, lockUniqKey)
+        redis.call("HINCRBY", readerCountKey, lockUniqKey, 1)
+        statusReaders = liveReaders()
+        local anonymous = get(readLockKey)
+        if anonymous ~= false and tonumber(anonymous) > 0
+        then
+            statusReaders = statusReaders + tonumber(anonymous)
+        end
+        -- 集合本身的过期时间不能短于最晚过期的读者
+        expireReaderSet(readersKey, readerCountKey, expireNum * 1000)
+        return true
+    end
 
     local retIncr = incr(readLockKey)
     if retIncr > 0
     then
+        statusReaders = retIncr + liveReaders()
         return true
     end
 
@@ -323,66 +819,782 @@ local function rlock()
     return false
 end
 
-local function runlock()
-    local rlock = get(readLockKey)
-    if rlock == false or tonumber(rlock) <= 0
+-- 按权重加读锁，占用weight个单位的容量，剩余容量不足时失败
+-- 写锁需要全部容量，所以有写锁时失败，有按权重的读者时写锁也会失败
+local function rlockWeighted()
+    local weight = tonumber(ARGV[3])
+    local capacity = tonumber(ARGV[4])
+    if weight == nil or capacity == nil or weight <= 0 or weight > capacity
     then
-        errorString = "RUnlock of unlocked"
+        errorString = "invalid weight"
         return false
     end
-    decr(readLockKey)
+    local wlock = get(writeLockKey)
+    if wlock ~= false and string.len(wlock) > 0
+    then
+        debugString = "weighted rlock fail,write lock occupy now,occupyUniqKey=" .. wlock
+        return false
+    end
+    if readersFrozen()
+    then
+        debugString = "weighted rlock fail,readers frozen,key==" .. lockKey
+        return false
+    end
+    if coolingDown()
+    then
+        debugString = "weighted rlock fail,lock is cooling down,key==" .. lockKey
+        return false
+    end
+    local _, used = liveWeighted()
+    if used + weight > capacity
+    then
+        debugString = "weighted rlock fail,capacity not enough,used=" .. used .. ",weight=" .. weight .. ",capacity=" .. capacity
+        return false
+    end
+    redis.call("ZADD", weightedKey, nowMs() + expireNum * 1000, lockUniqKey)
+    redis.call("HINCRBY", weightKey, lockUniqKey, weight)
+    expireReaderSet(weightedKey, weightKey, expireNum * 1000)
     return true
 end
 
--- 处理锁逻辑
-local function handleLock()
-    if cmdKey == "LOCK"
+-- 释放按权重的读锁，占用的容量减到0时删除读者
+local function runlockWeighted()
+    local weight = tonumber(ARGV[3])
+    if weight == nil or weight <= 0
     then
-        if string.len(lockUniqKey) <= 0
-        then
-            errorString = "unque key is nil"
-            return false
-        end
-        if string.len(writeLockKey) <= 0
-        then
-            errorString = "Lock key is nil"
-            return false
-        end
-        return lock()
+        errorString = "invalid weight"
+        return false
+    end
+    if redis.call("ZSCORE", weightedKey, lockUniqKey) == false
+    then
+        debugString = "weighted RUnlock of unlocked or expired reader,uniqueID=" .. lockUniqKey
+        hdel(weightKey, lockUniqKey)
+        return true
+    end
+    if redis.call("HINCRBY", weightKey, lockUniqKey, -weight) <= 0
+    then
+        redis.call("ZREM", weightedKey, lockUniqKey)
+        hdel(weightKey, lockUniqKey)
     end
+    return true
+end
 
-    if cmdKey == "UNLOCK"
+-- 最后一个读者离开时通知等待的写者
+local function publishIfNoReaders()
+    local anonymous = get(readLockKey)
+    if anonymous ~= false and tonumber(anonymous) > 0
     then
-        if string.len(lockUniqKey) <= 0
+        return
+    end
+    if liveReaders() == 0
+    then
+        publishRelease("runlock")
+    end
+end
+
+-- 管理操作：强制移除一个读者，不管它的重入次数，返回读者是否存在
+local function evictReader()
+    local found = redis.call("ZREM", readersKey, lockUniqKey) > 0
+    hdel(readerCountKey, lockUniqKey)
+    if redis.call("ZREM", weightedKey, lockUniqKey) > 0
+    then
+        found = true
+    end
+    hdel(weightKey, lockUniqKey)
+    if not found
+    then
+        debugString = "evict reader not found,uniqueID=" .. lockUniqKey
+        return false
+    end
+    publishIfNoReaders()
+    return true
+end
+
+local function runlock()
+    if string.len(lockUniqKey) > 0
+    then
+        if redis.call("ZSCORE", readersKey, lockUniqKey) == false
         then
-            errorString = "unque key is nil"
-            return false
+            debugString = "RUnlock of unlocked or expired reader,uniqueID=" .. lockUniqKey
+            hdel(readerCountKey, lockUniqKey)
+            return true
         end
-        if string.len(writeLockKey) <= 0
+        -- 重入次数减到0才真正释放
+        if redis.call("HINCRBY", readerCountKey, lockUniqKey, -1) <= 0
         then
-            errorString = "Lock key is nil"
-            return false
+            removeReader(lockUniqKey)
+            publishIfNoReaders()
         end
-        return unlock()
+        return true
     end
 
-    if cmdKey == "RLOCK"
+    local rlock = get(readLockKey)
+    if rlock == false or tonumber(rlock) <= 0
     then
-        if string.len(readLockKey) <= 0
-        then
-            errorString = "Rlock key is nil"
-            return false
-        end
-        return rlock()
+        errorString = "RUnlock of unlocked"
+        return false
     end
-    if cmdKey == "RUNLOCK"
+    decr(readLockKey)
+    publishIfNoReaders()
+    return true
+end
+
+-- 刷新共享的过期时间，只会延长不会缩短
+local function refreshShared()
+    if redis.call("PTTL", sharedReadKey) < expireNum * 1000
     then
-        if string.len(readLockKey) <= 0
-        then
-            errorString = "RUnlock key is nil"
-            return false
-        end
-        return runlock()
+        redis.call("PEXPIRE", sharedReadKey, expireNum * 1000)
+    end
+end
+
+-- 共享过期时间的读锁，每次加锁只写一个key
+local function srlock()
+    local wlock = get(writeLockKey)
+    if wlock ~= false and string.len(wlock) > 0
+    then
+        debugString = "read srlock fail,write lock occupy now,key==" .. writeLockKey .. ",occupyUniqKey=" .. wlock
+        readerWait()
+        return false
+    end
+    if readersFrozen()
+    then
+        debugString = "read srlock fail,readers frozen,key==" .. lockKey
+        return false
+    end
+    if coolingDown()
+    then
+        debugString = "read srlock fail,lock is cooling down,key==" .. lockKey
+        return false
+    end
+    if readBatchFull()
+    then
+        debugString = "read srlock fail,read batch full,writer waiting,key==" .. lockKey
+        readerWait()
+        return false
+    end
+    readerDone()
+    incr(sharedReadKey)
+    refreshShared()
+    statusReaders = liveReaders()
+    local anonymous = get(readLockKey)
+    if anonymous ~= false and tonumber(anonymous) > 0
+    then
+        statusReaders = statusReaders + tonumber(anonymous)
+    end
+    return true
+end
+
+-- 释放共享过期时间的读锁，计数已经过期时直接返回
+local function srunlock()
+    if sharedReaders() <= 0
+    then
+        debugString = "SRUnlock of unlocked or expired shared readers"
+        return true
+    end
+    if decr(sharedReadKey) <= 0
+    then
+        del(sharedReadKey)
+    end
+    publishIfNoReaders()
+    return true
+end
+
+-- 刷新共享的过期时间，共享的读者已经过期时返回false
+local function srextend()
+    if sharedReaders() <= 0
+    then
+        debugString = "SRExtend of expired shared readers"
+        return false
+    end
+    refreshShared()
+    return true
+end
+
+-- 写锁续期，只有锁还是自己持有时才会续期
+local function extend()
+    local ret = get(writeLockKey)
+    if ret ~= lockUniqKey
+    then
+        if ret == false
+        then
+            ret = ""
+        end
+        debugString = "extend lock not owned,key=" .. writeLockKey .. ",expectUniqKey=" .. lockUniqKey .. ",owner=" .. ret
+        return false
+    end
+    refreshHolder()
+    if expireNum > 0
+    then
+        expire(metaKey, expireNum)
+        return expire(writeLockKey, expireNum) > 0
+    end
+    redis.call("PERSIST", writeLockKey)
+    redis.call("PERSIST", metaKey)
+    return true
+end
+
+-- 当前脚本使用的key和类型，MIGRATE据此识别旧的格式
+local function layout()
+    return {
+        {writeLockKey, "string"}, {readLockKey, "string"}, {metaKey, "string"},
+        {sharedReadKey, "string"}, {upgradeIntentKey, "string"},
+        {readersKey, "zset"}, {weightedKey, "zset"}, {readerWaitKey, "zset"},
+        {weightKey, "hash"}, {readerCountKey, "hash"}, {holderKey, "hash"},
+        {existHashKey, "hash"}, {readerSeenKey, "hash"},
+        {queueKey, "list"}
+    }
+end
+
+-- 升级锁的数据结构，和加锁释放在同一个脚本中，是原子的
+-- 版本1到当前版本只增加了新的key，已有的key格式没有变化，升级只需要清理版本1遗留的过期读者和离线等待者
+-- 以后修改已有key的格式时在这里识别旧的格式并转换；无法识别的类型不做修改，返回错误
+local function migrate()
+    for _, kv in ipairs(layout())
+    do
+        local t = redis.call("TYPE", kv[1])["ok"]
+        if t ~= "none" and t ~= kv[2]
+        then
+            errorString = "unknown layout: " .. kv[1] .. " is " .. t .. ", expect " .. kv[2]
+            return false
+        end
+    end
+    liveReaders()
+    cleanWaiters()
+    return true
+end
+
+-- 缩短写锁的剩余时间，ARGV[3]为新的剩余时间（毫秒），不能比当前的剩余时间长
+local function shorten()
+    local ret = get(writeLockKey)
+    if ret ~= lockUniqKey
+    then
+        debugString = "shorten lock not owned,key=" .. writeLockKey
+        return false
+    end
+    local ms = tonumber(ARGV[3]) or 0
+    if ms <= 0
+    then
+        errorString = "invalid shorten ttl"
+        return false
+    end
+    -- 永不过期（-1）的锁任何剩余时间都算缩短
+    local pttl = redis.call("PTTL", writeLockKey)
+    if pttl >= 0 and ms >= pttl
+    then
+        errorString = "shorten would extend"
+        return false
+    end
+    redis.call("PEXPIRE", writeLockKey, ms)
+    if exists(metaKey) > 0
+    then
+        redis.call("PEXPIRE", metaKey, ms)
+    end
+    redis.call("PEXPIRE", holderKey, ms + holderGrace * 1000)
+    return true
+end
+
+-- 设置写锁和过期时间
+local function setWriteLock()
+    set(writeLockKey, lockUniqKey)
+    if expireNum > 0
+    then
+        expire(writeLockKey, expireNum)
+    end
+    setMeta("")
+    setHolder("")
+end
+
+-- 条件加锁，检查和加锁在同一个脚本中完成
+-- ARGV[3]为空时要求锁完全空闲（没有写锁和读者），否则要求写锁当前由ARGV[3]持有
+local function lockIf()
+    local expectOwner = ARGV[3] or ""
+    local owner = get(writeLockKey)
+    if owner == false
+    then
+        owner = ""
+    end
+    if owner ~= expectOwner
+    then
+        debugString = "lockif state not expect,expectOwner=" .. expectOwner .. ",owner=" .. owner
+        return false
+    end
+    if string.len(expectOwner) <= 0
+    then
+        local anonymous = get(readLockKey)
+        if (anonymous ~= false and tonumber(anonymous) > 0) or liveReaders() > 0
+        then
+            debugString = "lockif expect free but readers present"
+            return false
+        end
+    end
+    -- 和普通加锁一样，释放后的冷却期内不能加锁
+    if coolingDown()
+    then
+        debugString = "lockif lock is cooling down,key==" .. lockKey
+        return false
+    end
+    setWriteLock()
+    return true
+end
+
+-- 把写锁从lockUniqKey原子地交给ARGV[3]，保留剩余的过期时间
+local function handoff()
+    local to = ARGV[3] or ""
+    if string.len(to) <= 0
+    then
+        errorString = "handoff target is nil"
+        return false
+    end
+    if get(writeLockKey) ~= lockUniqKey
+    then
+        debugString = "handoff lock not owned,key=" .. writeLockKey .. ",from=" .. lockUniqKey
+        return false
+    end
+    local pttl = redis.call("PTTL", writeLockKey)
+    set(writeLockKey, to)
+    if pttl > 0
+    then
+        redis.call("PEXPIRE", writeLockKey, pttl)
+    end
+    -- 元数据属于旧的持有者，交接不算接管过期的锁
+    setMeta("")
+    redis.call("HSET", holderKey, "owner", to, "meta", "", "trace", "")
+    return true
+end
+
+-- 读者续期，只有读者还在（未过期）时才会续期
+local function rextend()
+    liveReaders()
+    if redis.call("ZSCORE", readersKey, lockUniqKey) == false
+    then
+        debugString = "rextend reader not found,uniqueID=" .. lockUniqKey
+        return false
+    end
+    redis.call("ZADD", readersKey, "XX", nowMs() + expireNum * 1000, lockUniqKey)
+    expireReaderSet(readersKey, readerCountKey, expireNum * 1000)
+    return true
+end
+
+-- 重新获取锁：锁还是自己持有时续期，否则按正常流程尝试加锁一次
+local function reacquire()
+    if get(writeLockKey) == lockUniqKey
+    then
+        return extend()
+    end
+    return lock()
+end
+
+-- 读锁升级为写锁
+-- 只有自己是唯一的读者时才能升级，否则直接返回false，不排队等待
+local function upgrade()
+    if redis.call("ZSCORE", readersKey, lockUniqKey) == false
+    then
+        errorString = "Upgrade of non reader,uniqueID=" .. lockUniqKey
+        return false
+    end
+
+    local anonymous = get(readLockKey)
+    if anonymous ~= false and tonumber(anonymous) > 0
+    then
+        debugString = "anonymous read lock number(".. anonymous ..") > 0"
+        return false
+    end
+
+    local readers = liveReaders()
+    if readers > 1
+    then
+        debugString = "other readers present,readers=" .. readers
+        return false
+    end
+    -- 自己的读者记录可能刚好过期
+    if readers == 0
+    then
+        errorString = "Upgrade of expired reader,uniqueID=" .. lockUniqKey
+        return false
+    end
+
+    local wret = get(writeLockKey)
+    if wret ~= false and string.len(wret) > 0
+    then
+        debugString = "write lock be set by other"
+        return false
+    end
+
+    setWriteLock()
+    removeReader(lockUniqKey)
+    return true
+end
+
+-- 阻塞升级的单次尝试
+-- 先登记升级意向，已经有其他仍持有读锁的读者登记了意向时，两者会互相等待对方释放读锁，
+-- 此时让后来的一方失败（先登记的一方继续等待），避免两者都永远等下去
+local function upgradeWait()
+    if redis.call("ZSCORE", readersKey, lockUniqKey) == false
+    then
+        errorString = "Upgrade of non reader,uniqueID=" .. lockUniqKey
+        return false
+    end
+    local intent = get(upgradeIntentKey)
+    if intent ~= false and intent ~= lockUniqKey
+    then
+        liveReaders()
+        if redis.call("ZSCORE", readersKey, intent) ~= false
+        then
+            errorString = "upgrade deadlock"
+            debugString = "another reader is upgrading,uniqueID=" .. intent
+            return false
+        end
+    end
+    -- 登记（或刷新）自己的升级意向，进程崩溃后会自动过期
+    set(upgradeIntentKey, lockUniqKey)
+    expire(upgradeIntentKey, waitQueueExpire)
+
+    local ok = upgrade()
+    if ok
+    then
+        del(upgradeIntentKey)
+    end
+    return ok
+end
+
+-- 查询锁状态，只读取不修改（过期读者的清理除外）
+local function status()
+    local owner = get(writeLockKey)
+    if owner ~= false
+    then
+        statusOwner = owner
+        statusTTL = redis.call("PTTL", writeLockKey)
+        local meta = get(metaKey)
+        if meta ~= false
+        then
+            statusMeta = meta
+        end
+        -- 持有者记录比写锁多保留一段时间，只有属于当前持有者时才返回trace ID
+        local holder = redis.call("HMGET", holderKey, "owner", "trace")
+        if holder[1] == owner and holder[2]
+        then
+            statusTrace = holder[2]
+        end
+    end
+    local anonymous = get(readLockKey)
+    if anonymous ~= false and tonumber(anonymous) > 0
+    then
+        statusReaders = tonumber(anonymous)
+    end
+    statusReaders = statusReaders + liveReaders()
+    -- 列出未过期的读者，最多cleanLimit个，匿名读者没有ID不会列出
+    local now = nowMs()
+    local list = {}
+    for _, k in ipairs({readersKey, weightedKey})
+    do
+        local left = cleanLimit - #list
+        if left <= 0
+        then
+            break
+        end
+        local items = redis.call("ZRANGEBYSCORE", k, "(" .. now, "+inf", "WITHSCORES", "LIMIT", 0, left)
+        for i = 1, #items, 2
+        do
+            table.insert(list, {id = items[i], ttl = tonumber(items[i + 1]) - now})
+        end
+    end
+    if #list > 0
+    then
+        statusReaderList = list
+    end
+    return true
+end
+
+-- 加写锁前检查ARGV中的附加条件，通过后再按正常流程加锁
+local function checkedLock()
+    -- ARGV[7]为要求的最短剩余时间（毫秒），过期时间本身就不够时不用排队，直接失败
+    minTTL = tonumber(ARGV[7]) or 0
+    if minTTL > 0 and expireNum > 0 and expireNum * 1000 < minTTL
+    then
+        errorString = "lease shorter than min ttl"
+        return false
+    end
+    -- ARGV[8]为不允许的持有者，它持有写锁或者读锁时直接失败，不排队
+    local forbidden = ARGV[8]
+    if forbidden ~= nil and string.len(forbidden) > 0
+    then
+        local score = redis.call("ZSCORE", readersKey, forbidden)
+        if get(writeLockKey) == forbidden or (score and tonumber(score) > nowMs())
+        then
+            errorString = "held by forbidden owner"
+            return false
+        end
+    end
+    return lock()
+end
+
+-- 处理锁逻辑
+local function handleLock()
+    if cmdKey == "LOCK"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        if string.len(writeLockKey) <= 0
+        then
+            errorString = "Lock key is nil"
+            return false
+        end
+        local ok = checkedLock()
+        -- ARGV[10]为"1"时是调用方的最后一次尝试，失败时同时离开等待队列，不用再发一次LEAVE
+        if not ok and ARGV[10] == "1"
+        then
+            leaveQueue()
+        end
+        return ok
+    end
+
+    if cmdKey == "UNLOCK"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        if string.len(writeLockKey) <= 0
+        then
+            errorString = "Lock key is nil"
+            return false
+        end
+        return unlock()
+    end
+
+    if cmdKey == "RLOCK"
+    then
+        if string.len(readLockKey) <= 0
+        then
+            errorString = "Rlock key is nil"
+            return false
+        end
+        -- ARGV[3]为读者的公平窗口（毫秒），ARGV[4]为写者等待时放行的读者数量
+        readerWindow = tonumber(ARGV[3]) or 0
+        readBatch = tonumber(ARGV[4]) or 0
+        return readResult(rlock())
+    end
+    if cmdKey == "RUNLOCK"
+    then
+        if string.len(readLockKey) <= 0
+        then
+            errorString = "RUnlock key is nil"
+            return false
+        end
+        return runlock()
+    end
+
+    if cmdKey == "EXTEND"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        return extend()
+    end
+
+    if cmdKey == "SHORTEN"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        return shorten()
+    end
+
+    if cmdKey == "WRLOCK" or cmdKey == "WRUNLOCK"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        if cmdKey == "WRLOCK"
+        then
+            return rlockWeighted()
+        end
+        local ret = runlockWeighted()
+        if ret
+        then
+            publishIfNoReaders()
+        end
+        return ret
+    end
+
+    -- ARGV[3]为"1"时冻结新的读者，冻结的时间为expireNum秒；否则解除冻结并唤醒等待的读者
+    if cmdKey == "FREEZE"
+    then
+        if ARGV[3] == "1"
+        then
+            if expireNum <= 0
+            then
+                errorString = "freeze expire must be positive"
+                return false
+            end
+            redis.call("SET", freezeKey, lockUniqKey, "EX", expireNum)
+            return true
+        end
+        if del(freezeKey) > 0
+        then
+            publishRelease("unfreeze")
+        end
+        return true
+    end
+
+    if cmdKey == "HANDOFF"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        return handoff()
+    end
+
+    if cmdKey == "LOCKIF"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        return lockIf()
+    end
+
+    if cmdKey == "REXTEND"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        if expireNum <= 0
+        then
+            errorString = "rextend expire must be positive"
+            return false
+        end
+        return rextend()
+    end
+
+    if cmdKey == "REACQUIRE"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        return reacquire()
+    end
+
+    if cmdKey == "UPGRADE"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        if ARGV[3] == "1"
+        then
+            return upgradeWait()
+        end
+        return upgrade()
+    end
+
+    if cmdKey == "STATUS"
+    then
+        return status()
+    end
+
+    if cmdKey == "LEAVE"
+    then
+        return leaveQueue()
+    end
+
+    if cmdKey == "WAITERS"
+    then
+        statusWaiters = cleanWaiters()
+        return true
+    end
+
+    if cmdKey == "SRLOCK" or cmdKey == "SRUNLOCK" or cmdKey == "SREXTEND"
+    then
+        if expireNum <= 0 and cmdKey ~= "SRUNLOCK"
+        then
+            errorString = "shared read expire must be positive"
+            return false
+        end
+        if cmdKey == "SRLOCK"
+        then
+            readerWindow = tonumber(ARGV[3]) or 0
+            readBatch = tonumber(ARGV[4]) or 0
+            return readResult(srlock())
+        end
+        if cmdKey == "SRUNLOCK"
+        then
+            return srunlock()
+        end
+        return srextend()
+    end
+
+    -- 加锁成功后在同一个脚本中读取valueKey，读取到的值和锁的状态一致
+    -- RLOCKGET的valueKey为ARGV[3]，ARGV[4]、ARGV[5]为读者的公平窗口和放行数量，LOCKGET的ARGV[3]、ARGV[4]同LOCK，valueKey为ARGV[5]
+    if cmdKey == "RLOCKGET" or cmdKey == "LOCKGET"
+    then
+        local ret
+        local valueKey
+        if cmdKey == "RLOCKGET"
+        then
+            valueKey = ARGV[3]
+            readerWindow = tonumber(ARGV[4]) or 0
+            readBatch = tonumber(ARGV[5]) or 0
+            ret = rlock()
+        else
+            valueKey = ARGV[5]
+            if string.len(lockUniqKey) <= 0
+            then
+                errorString = "unque key is nil"
+                return false
+            end
+            ret = lock()
+        end
+        if ret and valueKey ~= nil and string.len(valueKey) > 0
+        then
+            local v = get(valueKey)
+            if v ~= false
+            then
+                statusValue = v
+            end
+        end
+        return ret
+    end
+
+    if cmdKey == "EVICTREADER"
+    then
+        if string.len(lockUniqKey) <= 0
+        then
+            errorString = "unque key is nil"
+            return false
+        end
+        return evictReader()
+    end
+
+    -- 检查锁的数据结构并升级到当前脚本的格式，已经是当前格式时只做清理
+    if cmdKey == "MIGRATE"
+    then
+        return migrate()
+    end
+
+    if cmdKey == "GC"
+    then
+        liveReaders()
+        cleanWaiters()
+        return true
     end
 
     errorString = "Unkown rwlock Command"
@@ -391,8 +1603,60 @@ end
 
 local opRet = handleLock()
 
+-- ok: 成功；busy: 锁被占用，可以重试；error: 致命错误，不要重试
+local status = "busy"
+if string.len(errorString) > 0
+then
+    status = "error"
+elseif opRet
+then
+    status = "ok"
+end
+
+-- 记录到审计stream的指令
+local auditCmds = {
+    LOCK = true, UNLOCK = true, RLOCK = true, RUNLOCK = true,
+    SRLOCK = true, SRUNLOCK = true, WRLOCK = true, WRUNLOCK = true,
+    UPGRADE = true, LOCKIF = true, REACQUIRE = true, HANDOFF = true,
+    LOCKGET = true, RLOCKGET = true, EVICTREADER = true
+}
+-- 成功改变了锁状态的指令追加到审计stream，和状态变化在同一个脚本中，是原子的
+-- 写锁已经不是自己的时UNLOCK也返回成功，这种情况不记录
+if auditMaxLen > 0 and status == "ok" and auditCmds[cmdKey] and (cmdKey ~= "UNLOCK" or statusOwner == lockUniqKey)
+then
+    redis.call("XADD", "rwlock:audit:" .. lockKey, "MAXLEN", "~", auditMaxLen, "*",
+        "op", cmdKey, "id", lockUniqKey, "expire", tostring(expireNum), "meta", statusMeta)
+end
+
+-- KEYS[3]为"int"时成功和占用只返回1和0，省去JSON编码；错误和冷却期仍然返回JSON，带上错误信息和冷却期剩余时间
+if KEYS[3] == "int" and status ~= "error" and statusCooldown <= 0
+then
+    if status == "ok"
+    then
+        return 1
+    end
+    return 0
+end
+
 return cjson.encode({
     opRet = opRet,
     debug = debugString,
-    errMsg = errorString
+    errMsg = errorString,
+    version = scriptVersion,
+    owner = statusOwner,
+    ttl = statusTTL,
+    readers = statusReaders,
+    waiters = statusWaiters,
+    position = queuePosition,
+    meta = statusMeta,
+    trace = statusTrace,
+    readerList = statusReaderList,
+    value = statusValue,
+    prevOwner = statusPrevOwner,
+    prevMeta = statusPrevMeta,
+    fence = statusFence,
+    cooldown = statusCooldown,
+    more = moreClean,
+    cleaned = cleaned,
+    status = status
 })
\ No newline at end of file
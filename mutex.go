@@ -0,0 +1,138 @@
+package rwlock
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/tool"
+)
+
+// DistributedRWMutex
+// 方法和sync.RWMutex一致的分布式读写锁，绑定一个固定的key，用于把进程内的sync.RWMutex机械地替换成分布式锁
+// 每次加写锁自动生成一个token，释放时使用同一个token，和sync.RWMutex一样可以由其他协程释放
+// 和sync.RWMutex的区别：
+//   - 写锁默认10秒过期，持有超过过期时间后会被别人拿到，可以用 SetExpire 修改
+//   - redis出错时Lock、Unlock、RLock、RUnlock会panic，TryLock 遇到错误返回false，需要处理错误时直接使用 client 包的函数
+//   - 和sync.RWMutex一样不可重入：同一个协程持有写锁时再次Lock会一直阻塞；Unlock没有加锁的写锁会panic
+//   - 读锁也会过期：同一个 DistributedRWMutex 的所有读者共用一个uniqID，按重入计数，
+//     过期时间为客户端的读锁过期时间（WithReadExpire，默认10秒），每次RLock都会刷新；进程崩溃后读锁随之过期，不会永久挡住写者
+//   - RUnlock不区分是本进程的哪个协程加的读锁
+//   - 必须通过 NewDistributedRWMutex 创建，零值不能使用
+type DistributedRWMutex struct {
+	c      *client.Client
+	key    string
+	prefix string
+	expire int64
+	// 进程内排队的写者，保证同一时间本进程只有一个写者访问redis，也保证了不可重入
+	w chan struct{}
+	// 当前写锁的token
+	token string
+	seq   uint64
+}
+
+// NewDistributedRWMutex
+// 使用默认客户端创建key上的分布式读写锁
+func NewDistributedRWMutex(key string) *DistributedRWMutex {
+	return NewDistributedRWMutexWithClient(client.Default(), key)
+}
+
+// NewDistributedRWMutexWithClient
+// 使用指定的客户端创建key上的分布式读写锁
+func NewDistributedRWMutexWithClient(c *client.Client, key string) *DistributedRWMutex {
+	return &DistributedRWMutex{
+		c:      c,
+		key:    key,
+		prefix: tool.GetUUID(),
+		expire: 10,
+		w:      make(chan struct{}, 1),
+	}
+}
+
+// SetExpire
+// 设置写锁的过期时间（秒），之后的Lock生效
+func (m *DistributedRWMutex) SetExpire(sec int64) {
+	atomic.StoreInt64(&m.expire, sec)
+}
+
+// nextToken
+// 生成本次加写锁的token
+func (m *DistributedRWMutex) nextToken() string {
+	return m.prefix + "-" + strconv.FormatUint(atomic.AddUint64(&m.seq, 1), 10)
+}
+
+// Lock
+// 同 sync.RWMutex.Lock
+func (m *DistributedRWMutex) Lock() {
+	m.w <- struct{}{}
+	token := m.nextToken()
+	defer func() {
+		if p := recover(); p != nil {
+			<-m.w
+			panic(p)
+		}
+	}()
+	m.c.Lock(m.key, token, atomic.LoadInt64(&m.expire))
+	m.token = token
+}
+
+// TryLock
+// 同 sync.RWMutex.TryLock，只尝试一次，redis出错时返回false
+func (m *DistributedRWMutex) TryLock() bool {
+	select {
+	case m.w <- struct{}{}:
+	default:
+		return false
+	}
+	token := m.nextToken()
+	ok, err := m.c.TryLock(m.key, token, atomic.LoadInt64(&m.expire), 0)
+	if err != nil || !ok {
+		<-m.w
+		return false
+	}
+	m.token = token
+	return true
+}
+
+// Unlock
+// 同 sync.RWMutex.Unlock
+func (m *DistributedRWMutex) Unlock() {
+	if len(m.w) == 0 || m.token == "" {
+		panic("rwlock: unlock of unlocked DistributedRWMutex")
+	}
+	token := m.token
+	m.token = ""
+	defer func() { <-m.w }()
+	m.c.Unlock(m.key, token)
+}
+
+// RLock
+// 同 sync.RWMutex.RLock
+func (m *DistributedRWMutex) RLock() {
+	m.c.RLockID(m.key, m.prefix)
+}
+
+// TryRLock
+// 同 sync.RWMutex.TryRLock，只尝试一次，redis出错时返回false
+func (m *DistributedRWMutex) TryRLock() bool {
+	res, err := m.c.TryRLock(m.key, m.prefix)
+	return err == nil && res.Acquired
+}
+
+// RUnlock
+// 同 sync.RWMutex.RUnlock
+func (m *DistributedRWMutex) RUnlock() {
+	m.c.RUnlockID(m.key, m.prefix)
+}
+
+// RLocker
+// 同 sync.RWMutex.RLocker，返回的Locker的Lock和Unlock调用RLock和RUnlock
+func (m *DistributedRWMutex) RLocker() sync.Locker {
+	return (*rlocker)(m)
+}
+
+type rlocker DistributedRWMutex
+
+func (r *rlocker) Lock()   { (*DistributedRWMutex)(r).RLock() }
+func (r *rlocker) Unlock() { (*DistributedRWMutex)(r).RUnlock() }
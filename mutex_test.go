@@ -0,0 +1,186 @@
+package rwlock_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock"
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+func owner(t *testing.T, c *client.Client, key string) string {
+	t.Helper()
+	st, err := c.Status(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st.Owner
+}
+
+func TestMutexTokens(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	m := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+
+	m.Lock()
+	first := owner(t, c, "mu")
+	m.Unlock()
+	m.Lock()
+	second := owner(t, c, "mu")
+	m.Unlock()
+	if first == "" || first == second {
+		t.Fatalf("tokens %q and %q; want a new token per Lock", first, second)
+	}
+	prefix := first[:strings.LastIndex(first, "-")]
+	if !strings.HasPrefix(second, prefix+"-") {
+		t.Fatalf("tokens %q and %q do not share the mutex prefix", first, second)
+	}
+	if o := owner(t, c, "mu"); o != "" {
+		t.Fatalf("owner after Unlock = %q; want free", o)
+	}
+}
+
+// 和sync.RWMutex一样，可以由另一个协程释放
+func TestMutexUnlockFromAnotherGoroutine(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	m := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+
+	m.Lock()
+	done := make(chan struct{})
+	go func() {
+		m.Unlock()
+		close(done)
+	}()
+	<-done
+	if !m.TryLock() {
+		t.Fatal("TryLock after another goroutine unlocked = false")
+	}
+	m.Unlock()
+}
+
+// 不可重入：持有写锁时再次Lock一直阻塞到Unlock
+func TestMutexNotReentrant(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	m := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+
+	m.Lock()
+	if m.TryLock() {
+		t.Fatal("TryLock while locked = true")
+	}
+	locked := make(chan struct{})
+	go func() {
+		m.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("a second Lock succeeded while the first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	m.Unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second Lock never got the lock")
+	}
+	m.Unlock()
+}
+
+// 两个进程（这里是两个实例）之间互斥
+func TestMutexAcrossInstances(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	a := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+	b := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+
+	a.Lock()
+	if b.TryLock() || b.TryRLock() {
+		t.Fatal("b got the lock while a held the write lock")
+	}
+	a.Unlock()
+	a.RLock()
+	if b.TryLock() {
+		t.Fatal("b got the write lock while a held a read lock")
+	}
+	if !b.TryRLock() {
+		t.Fatal("b could not share the read lock")
+	}
+	b.RUnlock()
+	a.RUnlock()
+	if !b.TryLock() {
+		t.Fatal("b could not lock after every reader left")
+	}
+	b.Unlock()
+}
+
+// 读者用实例的uniqID加锁，同一个实例内重入计数，会过期
+func TestMutexReadersExpire(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t, client.WithReadExpire(3))
+	m := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+	r := m.RLocker()
+
+	r.Lock()
+	m.RLock()
+	st, err := c.Status(context.Background(), "mu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.ReaderList) != 1 || st.ReaderList[0].RemainingTTL <= 0 || st.ReaderList[0].RemainingTTL > 3*time.Second {
+		t.Fatalf("readers = %+v; want one expiring reader", st.ReaderList)
+	}
+	m.RUnlock()
+	if m.TryLock() {
+		t.Fatal("TryLock succeeded while one read reentry was still held")
+	}
+	r.Unlock()
+	if !m.TryLock() {
+		t.Fatal("TryLock after every RUnlock = false")
+	}
+	m.Unlock()
+}
+
+func TestMutexUnlockOfUnlockedPanics(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	m := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+	defer func() {
+		if p := recover(); p == nil || !strings.Contains(p.(string), "unlock of unlocked") {
+			t.Fatalf("recover() = %v; want the unlock of unlocked panic", p)
+		}
+	}()
+	m.Unlock()
+}
+
+// Lock panic后释放进程内的位置，之后还能加锁
+func TestMutexLockPanicReleasesSlot(t *testing.T) {
+	calls := 0
+	c, err := client.NewClientWithEvalSha(func(ctx context.Context, sha string, keys []string, args ...interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return "not json", nil
+		}
+		return `{"opRet":true,"status":"ok"}`, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := rwlock.NewDistributedRWMutexWithClient(c, "mu")
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Fatal("Lock did not panic on a malformed reply")
+			}
+		}()
+		m.Lock()
+	}()
+	if !m.TryLock() {
+		t.Fatal("TryLock after a panicking Lock = false")
+	}
+	m.Unlock()
+}
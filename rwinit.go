@@ -5,10 +5,10 @@ import (
 )
 
 // Init
-// 初始化redis客户端
-func Init(optObj interface{}) {
+// 初始化redis客户端，options为可选配置，见 client.Option
+func Init(optObj interface{}, options ...client.Option) {
 	// redis属于基础资源 如果redis的客户端初始化失败，直接panic，没得商量
-	if err := client.DoInit(optObj); err != nil {
+	if err := client.DoInit(optObj, options...); err != nil {
 		panic("redis client init ")
 	}
 }
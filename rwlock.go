@@ -10,28 +10,45 @@ type RWLock struct {
 	lockKey   string
 	uniqID    string
 	expire    int64
+	c         *client.Client
 }
 
 func New(key string) *RWLock {
+	return NewWithClient(client.Default(), key)
+}
+
+// NewWithClient
+// 使用指定的客户端创建锁，不依赖 Init 初始化的默认客户端
+func NewWithClient(c *client.Client, key string) *RWLock {
 	return &RWLock{
 		lockKey: key,
 		uniqID:  tool.GetUUID(),
 		expire:  10,
+		c:       c,
+	}
+}
+
+// NewFromKey
+// 同 New，key由 client.KB 构造，key不合法时返回错误
+func NewFromKey(k client.Key) (*RWLock, error) {
+	if err := k.Err(); err != nil {
+		return nil, err
 	}
+	return New(k.String()), nil
 }
 
 func (l *RWLock) Lock() {
-	client.Lock(l.lockKey, l.uniqID, l.expire)
+	l.c.Lock(l.lockKey, l.uniqID, l.expire)
 }
 
 func (l *RWLock) Unlock() {
-	client.Unlock(l.lockKey, l.uniqID)
+	l.c.Unlock(l.lockKey, l.uniqID)
 }
 
 func (l *RWLock) RLock() {
-	client.RLock(l.lockKey)
+	l.c.RLock(l.lockKey)
 }
 
 func (l *RWLock) RUnlock() {
-	client.RUnlock(l.lockKey)
+	l.c.RUnlock(l.lockKey)
 }
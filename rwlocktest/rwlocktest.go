@@ -0,0 +1,83 @@
+// Package rwlocktest
+// 测试辅助，基于进程内的miniredis，不需要启动真实的redis或Docker
+package rwlocktest
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	redis "github.com/go-redis/redis/v8"
+	"github.com/lzw5399/rwlock/client"
+)
+
+// NewTestClient
+// 启动一个miniredis，创建连接它的独立客户端（同 client.NewClient）并返回
+// 每次调用都是新的miniredis和客户端，不修改默认客户端，可以在t.Parallel()的测试中使用
+// cleanup会关闭客户端和miniredis，测试结束时也会通过t.Cleanup自动调用，可以重复调用
+// miniredis不支持CONFIG，默认关闭淘汰策略检查，options可以覆盖
+//
+//	func TestXxx(t *testing.T) {
+//		c, _ := rwlocktest.NewTestClient(t)
+//		c.Lock("key", "id", 5)
+//		defer c.Unlock("key", "id")
+//	}
+func NewTestClient(t testing.TB, options ...client.Option) (c *client.Client, cleanup func()) {
+	t.Helper()
+	c, _, cleanup = NewTestServer(t, options...)
+	return c, cleanup
+}
+
+// NewTestServer
+// 同 NewTestClient，同时返回miniredis，用于在测试中推进时间（FastForward）或者直接检查redis中的数据
+func NewTestServer(t testing.TB, options ...client.Option) (c *client.Client, m *miniredis.Miniredis, cleanup func()) {
+	t.Helper()
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	c, err = client.NewClient(&redis.Options{Addr: m.Addr()}, testOptions(options)...)
+	if err != nil {
+		m.Close()
+		t.Fatalf("init rwlock with miniredis: %v", err)
+	}
+	return c, m, register(t, c, m)
+}
+
+// InitTestDefault
+// 启动一个miniredis并用它初始化默认客户端（同 client.DoInit），包级别的函数（client.Lock、rwlock.New等）使用它
+// 默认客户端是进程内共享的，使用它的测试不能并行执行（t.Parallel()），否则会互相覆盖
+func InitTestDefault(t testing.TB, options ...client.Option) (c *client.Client, cleanup func()) {
+	t.Helper()
+	m, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("start miniredis: %v", err)
+	}
+	if err := client.DoInit(&redis.Options{Addr: m.Addr()}, testOptions(options)...); err != nil {
+		m.Close()
+		t.Fatalf("init rwlock with miniredis: %v", err)
+	}
+	c = client.Default()
+	return c, register(t, c, m)
+}
+
+// testOptions
+// miniredis不支持CONFIG，默认关闭淘汰策略检查
+func testOptions(options []client.Option) []client.Option {
+	return append([]client.Option{client.WithEvictionCheck(client.EvictionIgnore)}, options...)
+}
+
+// register
+// 返回关闭客户端和miniredis的cleanup，并注册到t.Cleanup
+func register(t testing.TB, c *client.Client, m *miniredis.Miniredis) func() {
+	closed := false
+	cleanup := func() {
+		if closed {
+			return
+		}
+		closed = true
+		_ = c.Close()
+		m.Close()
+	}
+	t.Cleanup(cleanup)
+	return cleanup
+}
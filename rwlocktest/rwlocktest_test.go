@@ -0,0 +1,178 @@
+package rwlocktest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+	"github.com/lzw5399/rwlock/rwlocktest"
+)
+
+func TestLockUnlock(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+
+	c.Lock("order:1", "a", 5)
+	if ok, err := c.TryLock("order:1", "b", 5, 0); err != nil || ok {
+		t.Fatalf("TryLock while held = %v, %v; want false, nil", ok, err)
+	}
+	c.Unlock("order:1", "a")
+	if ok, err := c.TryLock("order:1", "b", 5, 0); err != nil || !ok {
+		t.Fatalf("TryLock after unlock = %v, %v; want true, nil", ok, err)
+	}
+	c.Unlock("order:1", "b")
+}
+
+func TestRLockBlocksWriter(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t, client.WithReadExpire(5))
+
+	c.RLockID("order:1", "r1")
+	c.RLockID("order:1", "r2")
+	c.RLock("order:1")
+	st, err := c.Status(context.Background(), "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Readers != 3 || len(st.ReaderList) != 2 {
+		t.Fatalf("Status = %+v; want 3 readers, 2 listed", st)
+	}
+	if ok, _ := c.TryLock("order:1", "w", 5, 0); ok {
+		t.Fatal("TryLock succeeded while readers hold the lock")
+	}
+	c.RUnlockID("order:1", "r1")
+	c.RUnlockID("order:1", "r2")
+	c.RUnlock("order:1")
+	if ok, err := c.TryLock("order:1", "w", 5, 0); err != nil || !ok {
+		t.Fatalf("TryLock after readers left = %v, %v; want true, nil", ok, err)
+	}
+}
+
+// 同一个uniqID的读锁可以重入，释放相同次数后才会离开
+func TestRLockReentryBalance(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t, client.WithReadExpire(30))
+
+	c.RLockID("order:1", "r1")
+	c.RLockID("order:1", "r1")
+	readers := func() []client.ReaderInfo {
+		st, err := c.Status(context.Background(), "order:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.ReaderList
+	}
+	if list := readers(); len(list) != 1 || list[0].ID != "r1" {
+		t.Fatalf("readers after two RLockID = %+v; want r1 once", list)
+	}
+
+	c.RUnlockID("order:1", "r1")
+	if list := readers(); len(list) != 1 || list[0].ID != "r1" {
+		t.Fatalf("readers after one RUnlockID = %+v; want r1 still present", list)
+	}
+	if ok, _ := c.TryLock("order:1", "w", 5, 0); ok {
+		t.Fatal("TryLock succeeded while r1 still holds one read lock")
+	}
+
+	c.RUnlockID("order:1", "r1")
+	if list := readers(); len(list) != 0 {
+		t.Fatalf("readers after two RUnlockID = %+v; want none", list)
+	}
+	if ok, err := c.TryLock("order:1", "w", 5, 0); err != nil || !ok {
+		t.Fatalf("TryLock after balanced unlocks = %v, %v; want true, nil", ok, err)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	ctx := context.Background()
+
+	c.RLockID("order:1", "a")
+	c.RLockID("order:1", "b")
+	if ok, err := c.TryUpgrade("order:1", "a", 5); err != nil || ok {
+		t.Fatalf("TryUpgrade with another reader = %v, %v; want false, nil", ok, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Upgrade(ctx, "order:1", "a", 5) }()
+	select {
+	case err := <-done:
+		t.Fatalf("Upgrade returned %v before the other reader left", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	c.RUnlockID("order:1", "b")
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Upgrade did not finish after the other reader left")
+	}
+
+	st, err := c.Status(ctx, "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Owner != "a" || st.Readers != 0 {
+		t.Fatalf("Status after upgrade = %+v; want owner a, no readers", st)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+	c, _ := rwlocktest.NewTestClient(t)
+	ctx := context.Background()
+
+	st, err := c.Status(ctx, "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Owner != "" || st.Readers != 0 {
+		t.Fatalf("Status of a free lock = %+v", st)
+	}
+	c.Lock("order:1", "a", 5)
+	st, err = c.Status(ctx, "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Owner != "a" || st.TTL <= 0 || st.TTL > 5*time.Second {
+		t.Fatalf("Status of a held lock = %+v; want owner a, ttl in (0, 5s]", st)
+	}
+}
+
+func TestGCReaders(t *testing.T) {
+	t.Parallel()
+	c, m, _ := rwlocktest.NewTestServer(t, client.WithReadExpire(2))
+	ctx := context.Background()
+
+	c.RLockID("order:1", "r1")
+	c.RLockID("order:1", "r2")
+	// 读者按redis的TIME过期，把miniredis的时间推进到过期之后
+	m.SetTime(time.Now().Add(time.Minute))
+	for more := true; more; {
+		var err error
+		if more, err = c.GCReaders(ctx, "order:1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := c.Status(ctx, "order:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Readers != 0 || len(st.ReaderList) != 0 {
+		t.Fatalf("Status after GC = %+v; want no readers", st)
+	}
+	if ok, err := c.TryLock("order:1", "w", 5, 0); err != nil || !ok {
+		t.Fatalf("TryLock after GC = %v, %v; want true, nil", ok, err)
+	}
+}
+
+func TestCleanupIsIdempotent(t *testing.T) {
+	c, cleanup := rwlocktest.NewTestClient(t)
+	c.Lock("order:1", "a", 5)
+	cleanup()
+	cleanup()
+}
@@ -0,0 +1,223 @@
+// Package stats
+// 基于 client.WithEventHandler 的事件统计写锁的持有时间，按key给出滑动窗口内的分位数
+//
+//	rec := stats.NewHoldTimes(time.Minute)
+//	client.DoInit(opt, client.WithEventHandler(rec.Handle))
+//	p99, ok := rec.Percentile("key", 0.99)
+package stats
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+)
+
+// 每个key最多保留的样本数，超过时丢弃最早的样本
+const DefaultMaxSamples = 4096
+
+// KeyStats
+// 一个key在窗口内的持有时间统计
+type KeyStats struct {
+	// 窗口内完成的加锁释放次数
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+	// 加锁后没有匹配的释放就被别人拿到了锁的次数（锁过期丢失），不计入分位数，也不受窗口限制
+	Lost int64
+}
+
+type sample struct {
+	at   time.Time
+	hold time.Duration
+}
+
+// HoldTimes
+// 把加锁事件和释放事件按key+uniqID配对，记录每次的持有时间，可以并发使用
+type HoldTimes struct {
+	window     time.Duration
+	maxSamples int
+
+	mu sync.Mutex
+	// 已经加锁还没释放的持有者和加锁时间，按key索引，同一个key通常只有一个
+	open map[string]map[string]time.Time
+	// 上一次清理过期的持有者的时间
+	swept time.Time
+	// 每个key按时间顺序的样本
+	samples map[string][]sample
+	lost    map[string]int64
+}
+
+// NewHoldTimes
+// 创建统计，只使用最近window内释放的样本，window小于等于0时使用所有样本（仍然受 DefaultMaxSamples 限制），也不丢弃没有释放的持有者
+func NewHoldTimes(window time.Duration) *HoldTimes {
+	return &HoldTimes{
+		window:     window,
+		maxSamples: DefaultMaxSamples,
+		open:       make(map[string]map[string]time.Time),
+		samples:    make(map[string][]sample),
+		lost:       make(map[string]int64),
+	}
+}
+
+// Handle
+// 处理一个事件，作为 client.WithEventHandler 的回调；需要同时做其他处理时在自己的回调中调用它
+// 同一个持有者重入时按第一次加锁的时间计算，没有匹配加锁的释放（如统计开始前就持有的锁）忽略
+// 窗口大于0时，加锁超过一个窗口还没有释放的持有者会被丢弃（通常是进程崩溃没有释放事件），之后的释放也忽略
+func (h *HoldTimes) Handle(ev client.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sweep(ev.Time)
+	switch ev.Type {
+	case client.EventAcquired:
+		holders := h.open[ev.Key]
+		// 写锁是互斥的，同一个key还有别的持有者没释放，说明它的锁过期丢失了
+		for token := range holders {
+			if token != ev.UniqID {
+				delete(holders, token)
+				h.lost[ev.Key]++
+			}
+		}
+		if holders == nil {
+			holders = make(map[string]time.Time, 1)
+			h.open[ev.Key] = holders
+		}
+		if _, ok := holders[ev.UniqID]; !ok {
+			holders[ev.UniqID] = ev.Time
+		}
+	case client.EventReleased:
+		holders := h.open[ev.Key]
+		start, ok := holders[ev.UniqID]
+		if !ok {
+			return
+		}
+		delete(holders, ev.UniqID)
+		if len(holders) == 0 {
+			delete(h.open, ev.Key)
+		}
+		s := append(h.samples[ev.Key], sample{at: ev.Time, hold: ev.Time.Sub(start)})
+		if len(s) > h.maxSamples {
+			s = s[len(s)-h.maxSamples:]
+		}
+		h.samples[ev.Key] = s
+	}
+}
+
+// sweep
+// 每隔一个窗口丢弃一次加锁超过一个窗口还没有释放的持有者，避免不再使用的key一直占用内存，调用方持有锁
+func (h *HoldTimes) sweep(now time.Time) {
+	if h.window <= 0 || now.Sub(h.swept) < h.window {
+		return
+	}
+	h.swept = now
+	cutoff := now.Add(-h.window)
+	for key, holders := range h.open {
+		for token, start := range holders {
+			if start.Before(cutoff) {
+				delete(holders, token)
+			}
+		}
+		if len(holders) == 0 {
+			delete(h.open, key)
+		}
+	}
+}
+
+// Percentile
+// 返回key在窗口内持有时间的q分位数（0~1），窗口内没有样本时返回false
+func (h *HoldTimes) Percentile(key string, q float64) (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	holds := h.sorted(key)
+	if len(holds) == 0 {
+		return 0, false
+	}
+	return percentile(holds, q), true
+}
+
+// Stats
+// 返回key的统计，没有任何记录时返回零值
+func (h *HoldTimes) Stats(key string) KeyStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats(key)
+}
+
+// Snapshot
+// 返回所有有样本或者丢失记录的key的统计
+func (h *HoldTimes) Snapshot() map[string]KeyStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ret := make(map[string]KeyStats, len(h.samples))
+	for key := range h.samples {
+		ret[key] = h.stats(key)
+	}
+	for key := range h.lost {
+		if _, ok := ret[key]; !ok {
+			ret[key] = h.stats(key)
+		}
+	}
+	return ret
+}
+
+// Lost
+// 返回key加锁后没有匹配释放的次数
+func (h *HoldTimes) Lost(key string) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lost[key]
+}
+
+// stats
+// 同 Stats，调用方持有锁
+func (h *HoldTimes) stats(key string) KeyStats {
+	st := KeyStats{Lost: h.lost[key]}
+	holds := h.sorted(key)
+	if len(holds) == 0 {
+		return st
+	}
+	st.Count = len(holds)
+	st.P50 = percentile(holds, 0.5)
+	st.P90 = percentile(holds, 0.9)
+	st.P99 = percentile(holds, 0.99)
+	st.Max = holds[len(holds)-1]
+	return st
+}
+
+// sorted
+// 丢弃窗口外的样本，返回排好序的持有时间，调用方持有锁
+func (h *HoldTimes) sorted(key string) []time.Duration {
+	s := h.samples[key]
+	if h.window > 0 {
+		cutoff := time.Now().Add(-h.window)
+		i := sort.Search(len(s), func(i int) bool { return s[i].at.After(cutoff) })
+		s = s[i:]
+		if len(s) == 0 {
+			delete(h.samples, key)
+		} else {
+			h.samples[key] = s
+		}
+	}
+	holds := make([]time.Duration, len(s))
+	for i, v := range s {
+		holds[i] = v.hold
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i] < holds[j] })
+	return holds
+}
+
+// percentile
+// 最近秩法取分位数，holds已排序且不为空
+func percentile(holds []time.Duration, q float64) time.Duration {
+	if q <= 0 {
+		return holds[0]
+	}
+	if q >= 1 {
+		return holds[len(holds)-1]
+	}
+	return holds[int(math.Ceil(q*float64(len(holds))))-1]
+}
@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lzw5399/rwlock/client"
+)
+
+func acquired(key, token string, at time.Time) client.Event {
+	return client.Event{Type: client.EventAcquired, Key: key, UniqID: token, Time: at}
+}
+
+func released(key, token string, at time.Time) client.Event {
+	return client.Event{Type: client.EventReleased, Key: key, UniqID: token, Time: at}
+}
+
+func TestHoldTimesPairs(t *testing.T) {
+	h := NewHoldTimes(0)
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		token := strconv.Itoa(i)
+		h.Handle(acquired("k", token, now))
+		h.Handle(released("k", token, now.Add(time.Duration(i)*time.Millisecond)))
+	}
+	st := h.Stats("k")
+	if st.Count != 10 || st.P50 != 5*time.Millisecond || st.P90 != 9*time.Millisecond || st.Max != 10*time.Millisecond {
+		t.Fatalf("Stats = %+v", st)
+	}
+	// 没有匹配的加锁的释放忽略
+	h.Handle(released("k", "unknown", now))
+	if st := h.Stats("k"); st.Count != 10 {
+		t.Fatalf("unmatched release was counted: %+v", st)
+	}
+}
+
+func TestHoldTimesLost(t *testing.T) {
+	h := NewHoldTimes(0)
+	now := time.Now()
+	h.Handle(acquired("a", "1", now))
+	h.Handle(acquired("b", "1", now))
+	// a上的新持有者说明旧的丢了锁，b不受影响
+	h.Handle(acquired("a", "2", now.Add(time.Second)))
+	if h.Lost("a") != 1 || h.Lost("b") != 0 {
+		t.Fatalf("lost a=%d b=%d; want 1, 0", h.Lost("a"), h.Lost("b"))
+	}
+	h.Handle(released("a", "1", now.Add(2*time.Second)))
+	h.Handle(released("b", "1", now.Add(2*time.Second)))
+	if st := h.Stats("a"); st.Count != 0 {
+		t.Fatalf("release of the lost holder was sampled: %+v", st)
+	}
+	if st := h.Stats("b"); st.Count != 1 || st.Max != 2*time.Second {
+		t.Fatalf("b stats = %+v", st)
+	}
+}
+
+// 超过一个窗口没有释放的持有者被丢弃，不会一直留在内存中
+func TestHoldTimesAgesOutOpenHolders(t *testing.T) {
+	h := NewHoldTimes(time.Minute)
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 100; i++ {
+		h.Handle(acquired("crashed:"+strconv.Itoa(i), "x", start))
+	}
+	if n := len(h.open); n != 100 {
+		t.Fatalf("open = %d; want 100", n)
+	}
+	h.Handle(acquired("live", "y", start.Add(2*time.Minute)))
+	if n := len(h.open); n != 1 {
+		t.Fatalf("open after a window = %d; want only the live holder", n)
+	}
+	h.Handle(released("crashed:1", "x", start.Add(2*time.Minute)))
+	if st := h.Stats("crashed:1"); st.Count != 0 {
+		t.Fatalf("release of an aged-out holder was sampled: %+v", st)
+	}
+}
+
+func TestHoldTimesReentry(t *testing.T) {
+	h := NewHoldTimes(0)
+	now := time.Now()
+	h.Handle(acquired("k", "1", now))
+	h.Handle(acquired("k", "1", now.Add(time.Second)))
+	h.Handle(released("k", "1", now.Add(3*time.Second)))
+	if st := h.Stats("k"); st.Count != 1 || st.Max != 3*time.Second || st.Lost != 0 {
+		t.Fatalf("Stats = %+v; want one 3s hold from the first acquire", st)
+	}
+}
+
+// 大量不同key的持有者时，处理一个事件不随持有者数量变慢
+func BenchmarkHoldTimesManyKeys(b *testing.B) {
+	h := NewHoldTimes(time.Minute)
+	now := time.Now()
+	for i := 0; i < 100000; i++ {
+		h.Handle(acquired("held:"+strconv.Itoa(i), "x", now))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := "bench:" + strconv.Itoa(i%1024)
+		h.Handle(acquired(key, "t", now))
+		h.Handle(released(key, "t", now))
+	}
+}
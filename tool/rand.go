@@ -2,9 +2,37 @@ package tool
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
 func Rand(min, max int) int {
 	return rand.New(rand.NewSource(time.Now().UnixNano())).Intn(max-min) + min
 }
+
+// LockedRand
+// 并发安全的随机数生成器，rand.Rand本身不是并发安全的
+type LockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// NewLockedRand
+// 使用指定的随机源创建，src为nil时用当前时间作为种子
+func NewLockedRand(src rand.Source) *LockedRand {
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+	return &LockedRand{r: rand.New(src)}
+}
+
+// Int63n
+// 返回[0, n)之间的随机数，n小于等于0时返回0
+func (l *LockedRand) Int63n(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
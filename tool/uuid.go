@@ -1,11 +1,32 @@
 package tool
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"github.com/sony/sonyflake"
 	"strconv"
 )
 
-var sonyflakeGen = sonyflake.NewSonyflake(sonyflake.Settings{})
+var sonyflakeGen = newSonyflake()
+
+// newSonyflake
+// 默认用私有IP的低16位作为机器ID，没有私有IP（容器、CI环境等）时sonyflake返回nil，改用随机的机器ID
+func newSonyflake() *sonyflake.Sonyflake {
+	if gen := sonyflake.NewSonyflake(sonyflake.Settings{}); gen != nil {
+		return gen
+	}
+	return sonyflake.NewSonyflake(sonyflake.Settings{MachineID: randomMachineID})
+}
+
+// randomMachineID
+// 随机的16位机器ID
+func randomMachineID() (uint16, error) {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b[:]), nil
+}
 
 func GetUUID() string {
 	uuid, _ := sonyflakeGen.NextID()